@@ -0,0 +1,38 @@
+package assets
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// FS holds the templates and the static files bundled into the binary.
+//
+//go:embed template/*.html static
+var FS embed.FS
+
+type overlay struct {
+	dir fs.FS
+}
+
+// Open opens name from the override directory if it exists there, or from
+// the bundled files otherwise.
+func (o *overlay) Open(name string) (fs.File, error) {
+	file, err := o.dir.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+
+	return FS.Open(name)
+}
+
+// New returns the bundled files, each overridden by the file having the same
+// path in dir if it is set, e.g. "dir/static/css/fudge.css".
+func New(dir string) fs.FS {
+	if dir == "" {
+		return FS
+	}
+
+	return &overlay{os.DirFS(dir)}
+}
@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = os.MkdirAll(filepath.Join(dir, "static", "css"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "static", "css", "fudge.css"),
+		[]byte("body {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundled, err := fs.ReadFile(New(""), "static/css/fudge.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"static/css/fudge.css", "body {}\n"},
+		{"static/css/dark.css", ""},
+		{"template/_layout.html", ""},
+	}
+
+	for _, test := range tests {
+		b, err := fs.ReadFile(New(dir), test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.want != "" && string(b) != test.want {
+			t.Errorf("wrong contents for %s: got %q want %q", test.name, b, test.want)
+		}
+
+		if test.want == "" && len(b) == 0 {
+			t.Errorf("expected %s to be bundled", test.name)
+		}
+	}
+
+	if string(bundled) == "body {}\n" {
+		t.Error("expected the bundled files not to be overridden")
+	}
+
+	_, err = New(dir).Open("static/nonexistent")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
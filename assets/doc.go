@@ -0,0 +1 @@
+package assets // import "bovarys.me/fudge/assets"
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"bovarys.me/fudge/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultRealm is the realm of HTTP Basic authentication if none is set.
+const DefaultRealm = "fudge"
+
+// unknownUserHash is compared to the passwords of unknown users, so that they
+// take as long to be rejected as wrong passwords of existing users.
+var unknownUserHash = []byte("$2a$10$FwYIwd2hC4jlQt.ATGmdgukmZT9KOMp8ydCofju0WkqWJQ4pswJxq")
+
+// Everyone is the user name allowing any authenticated user to read a private
+// repository.
+const Everyone = "*"
+
+// Auth authenticates users and decides which repositories they can read.
+type Auth struct {
+	Realm string
+
+	hashes  map[string][]byte   // User names to bcrypt hashes
+	private map[string][]string // Repository name patterns to users
+
+	key      []byte // The random key of the HMACs of verified passwords
+	mu       sync.Mutex
+	verified map[string][sha256.Size]byte // User names to verified password HMACs
+}
+
+// New returns the users and the private repositories of cfg, reading its
+// htpasswd file if it is set. Only bcrypt hashes are supported.
+func New(cfg *config.AuthConfig) (*Auth, error) {
+	a := &Auth{
+		Realm:    cfg.Realm,
+		hashes:   make(map[string][]byte),
+		private:  cfg.Private,
+		key:      make([]byte, sha256.Size),
+		verified: make(map[string][sha256.Size]byte),
+	}
+
+	_, err := rand.Read(a.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Realm == "" {
+		a.Realm = DefaultRealm
+	}
+
+	if cfg.Htpasswd != "" {
+		err := a.readHtpasswd(cfg.Htpasswd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name, hash := range cfg.Users {
+		err := a.addUser(name, hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Auth) addUser(name, hash string) error {
+	if name == "" || name == Everyone || strings.Contains(name, ":") {
+		return fmt.Errorf("invalid user name: %q", name)
+	}
+
+	_, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return fmt.Errorf("invalid bcrypt hash for user %q: %v", name, err)
+	}
+
+	a.hashes[name] = []byte(hash)
+
+	return nil
+}
+
+// readHtpasswd adds the users of an htpasswd file, made of "name:hash" lines.
+func (a *Auth) readHtpasswd(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid htpasswd line: %q", line)
+		}
+
+		err := a.addUser(fields[0], fields[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Enabled reports whether users can authenticate.
+func (a *Auth) Enabled() bool {
+	return len(a.hashes) != 0
+}
+
+// sum returns the HMAC of password, keyed with the key of a so that verified
+// passwords cannot be recovered from their sums.
+func (a *Auth) sum(password string) [sha256.Size]byte {
+	var sum [sha256.Size]byte
+
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(password))
+	copy(sum[:], mac.Sum(nil))
+
+	return sum
+}
+
+// Authenticate reports whether password is the password of the user. As
+// bcrypt is slow by design, passwords are only compared to their hash once.
+func (a *Auth) Authenticate(name, password string) bool {
+	hash, ok := a.hashes[name]
+	if !ok {
+		// Unknown users are rejected as slowly as wrong passwords, so that
+		// user names cannot be told apart by response times
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password))
+		return false
+	}
+
+	sum := a.sum(password)
+
+	a.mu.Lock()
+	verified, ok := a.verified[name]
+	a.mu.Unlock()
+
+	if ok && subtle.ConstantTimeCompare(sum[:], verified[:]) == 1 {
+		return true
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if err != nil {
+		return false
+	}
+
+	a.mu.Lock()
+	a.verified[name] = sum
+	a.mu.Unlock()
+
+	return true
+}
+
+// CanRead reports whether the user can read the repository, an empty user
+// name standing for anonymous users. Repositories are public unless their
+// name matches one of the private patterns, in which case only the users
+// listed by the matching patterns can read them.
+func (a *Auth) CanRead(user, repository string) bool {
+	public := true
+
+	for pattern, users := range a.private {
+		ok, _ := path.Match(pattern, repository)
+		if !ok {
+			continue
+		}
+
+		public = false
+
+		if user == "" {
+			return false
+		}
+
+		for _, u := range users {
+			if u == user || u == Everyone {
+				return true
+			}
+		}
+	}
+
+	return public
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"bovarys.me/fudge/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The password of alice is "alicepw", and the one of bob is "bobpw"
+var cfg = &config.AuthConfig{
+	Users: map[string]string{
+		"alice": "$2a$04$kAt2RBXvaTt7zRloxS80F.JN4AsIkLqzBSLas1p0lhJP1njlSsd46",
+	},
+	Htpasswd: "testdata/htpasswd",
+	Private: map[string][]string{
+		"secret":     {"alice"},
+		"team/*":     {"*"},
+		"team/board": {"bob"},
+	},
+}
+
+func TestNew(t *testing.T) {
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Realm != DefaultRealm {
+		t.Errorf("wrong realm: got %q want %q", a.Realm, DefaultRealm)
+	}
+
+	if !a.Enabled() {
+		t.Error("expected authentication to be enabled")
+	}
+
+	tests := []*config.AuthConfig{
+		{Htpasswd: "testdata/nonexistent"},
+		{Htpasswd: "testdata/sha.htpasswd"},
+		{Users: map[string]string{"alice": "alicepw"}},
+		{Users: map[string]string{"*": cfg.Users["alice"]}},
+	}
+
+	for _, test := range tests {
+		_, err := New(test)
+		if err == nil {
+			t.Errorf("expected %+v to be rejected", test)
+		}
+	}
+
+	a, err = New(&config.AuthConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Enabled() {
+		t.Error("expected authentication to be disabled")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"alice", "alicepw", true},
+		{"alice", "alicepw", true}, // Verified without bcrypt
+		{"alice", "bobpw", false},
+		{"bob", "bobpw", true},
+		{"bob", "", false},
+		{"carol", "carolpw", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		valid := a.Authenticate(test.name, test.password)
+		if valid != test.valid {
+			t.Errorf("wrong authentication of %s:%s: got %v want %v",
+				test.name, test.password, valid, test.valid)
+		}
+	}
+
+	// Verified passwords are kept as HMACs keyed differently by each Auth
+	b, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified := a.verified["alice"]
+	if verified != a.sum("alicepw") || verified == b.sum("alicepw") ||
+		verified == sha256.Sum256([]byte("alicepw")) {
+		t.Error("expected the verified password to be keyed")
+	}
+
+	if _, err := bcrypt.Cost(unknownUserHash); err != nil {
+		t.Errorf("invalid hash for unknown users: %s", err)
+	}
+}
+
+func TestCanRead(t *testing.T) {
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user       string
+		repository string
+		allowed    bool
+	}{
+		{"", "python", true},
+		{"alice", "python", true},
+		{"", "secret", false},
+		{"bob", "secret", false},
+		{"alice", "secret", true},
+		{"", "team/project", false},
+		{"alice", "team/project", true},
+		{"bob", "team/board", true},
+		{"alice", "team/board", true}, // Allowed by "team/*"
+		{"", "team/nested/deep", true},
+	}
+
+	for _, test := range tests {
+		allowed := a.CanRead(test.user, test.repository)
+		if allowed != test.allowed {
+			t.Errorf("wrong access of %q to %s: got %v want %v",
+				test.user, test.repository, allowed, test.allowed)
+		}
+	}
+}
@@ -0,0 +1 @@
+package auth // import "bovarys.me/fudge/auth"
@@ -0,0 +1 @@
+package cache // import "bovarys.me/fudge/cache"
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+type entry struct {
+	key   string
+	value interface{}
+	cost  int64
+}
+
+// LRU is a least recently used cache safe for concurrent use. Each value is
+// added with a cost, and the least recently used values are evicted once the
+// total cost exceeds the capacity of the cache.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int64
+	cost     int64
+	list     *list.List
+	elements map[string]*list.Element
+}
+
+// NewLRU returns a cache of the given capacity. A cache whose capacity is zero
+// or less never keeps any value.
+func NewLRU(capacity int64) *LRU {
+	return &LRU{
+		capacity: capacity,
+		list:     list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value cached for key, if any, and marks it as recently used.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.elements[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.list.MoveToFront(element)
+
+	return element.Value.(*entry).value, true
+}
+
+// Add caches value for key, replacing the previous value if any. Values whose
+// cost exceeds the capacity of the cache are not added.
+func (c *LRU) Add(key string, value interface{}, cost int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.elements[key]; ok {
+		c.remove(element)
+	}
+
+	if c.capacity <= 0 || cost > c.capacity {
+		return
+	}
+
+	element := c.list.PushFront(&entry{key, value, cost})
+	c.elements[key] = element
+	c.cost += cost
+
+	for c.cost > c.capacity {
+		c.remove(c.list.Back())
+	}
+}
+
+// Remove removes the value cached for key, if any.
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.elements[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of values in the cache.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.list.Len()
+}
+
+func (c *LRU) remove(element *list.Element) {
+	e := c.list.Remove(element).(*entry)
+
+	delete(c.elements, e.key)
+	c.cost -= e.cost
+}
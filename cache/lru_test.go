@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(3)
+
+	c.Add("a", 1, 1)
+	c.Add("b", 2, 1)
+	c.Add("c", 3, 1)
+
+	// Mark a as recently used, so that b is evicted first
+	value, ok := c.Get("a")
+	if !ok || value != 1 {
+		t.Errorf("wrong value for a: got %v want %v", value, 1)
+	}
+
+	c.Add("d", 4, 1)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %v to be cached", key)
+		}
+	}
+
+	// Replacing a value updates its cost
+	c.Add("a", 5, 2)
+
+	if c.Len() != 2 {
+		t.Errorf("wrong length: got %v want %v", c.Len(), 2)
+	}
+
+	value, ok = c.Get("a")
+	if !ok || value != 5 {
+		t.Errorf("wrong value for a: got %v want %v", value, 5)
+	}
+
+	// Values costing more than the capacity are not added
+	c.Add("e", 6, 4)
+
+	if _, ok := c.Get("e"); ok {
+		t.Error("expected e not to be cached")
+	}
+
+	c.Remove("a")
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to be removed")
+	}
+}
+
+func TestDisabledLRU(t *testing.T) {
+	c := NewLRU(0)
+
+	c.Add("a", 1, 0)
+	c.Add("b", 2, 1)
+
+	if c.Len() != 0 {
+		t.Errorf("wrong length: got %v want %v", c.Len(), 0)
+	}
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -13,13 +18,113 @@ type LoggerConfig struct {
 	Priority string `yaml:"priority"`
 }
 
+type CacheConfig struct {
+	Repositories int64 `yaml:"repositories"`
+	Trees        int64 `yaml:"trees"`
+	Blobs        int64 `yaml:"blobs"`
+	LastCommits  int64 `yaml:"last-commits"`
+	Comparisons  int64 `yaml:"comparisons"`
+}
+
+type ListenerConfig struct {
+	Address    string `yaml:"address"`     // A TCP address, e.g. "localhost:8080"
+	Socket     string `yaml:"socket"`      // The path of a Unix domain socket
+	SocketMode string `yaml:"socket-mode"` // The socket octal permissions, e.g. "0660"
+	TLSCert    string `yaml:"tls-cert"`
+	TLSKey     string `yaml:"tls-key"`
+}
+
+type TimeoutsConfig struct {
+	Read     time.Duration `yaml:"read"`
+	Write    time.Duration `yaml:"write"`
+	Idle     time.Duration `yaml:"idle"`
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+type MetricsConfig struct {
+	Enable bool `yaml:"enable"`
+	// If set, metrics are served on this listener instead of the others
+	Listener *ListenerConfig `yaml:"listener"`
+}
+
+type SyntaxConfig struct {
+	Light string `yaml:"light"` // The chroma style of the light theme
+	Dark  string `yaml:"dark"`  // The chroma style of the dark theme
+	// Styles maps the names of custom styles to their entries, themselves
+	// mapping token types to chroma style entries
+	Styles map[string]map[string]string `yaml:"styles"`
+}
+
+type HealthConfig struct {
+	// The name of a repository opened by readiness checks, if set
+	Repository string `yaml:"repository"`
+}
+
+// RepositoryConfig overrides the metadata read from a repository.
+type RepositoryConfig struct {
+	Description   string `yaml:"description"`
+	Owner         string `yaml:"owner"`
+	Homepage      string `yaml:"homepage"`
+	Hidden        *bool  `yaml:"hidden"` // Unset to use the repository value
+	DefaultBranch string `yaml:"default-branch"`
+	Section       string `yaml:"section"`
+}
+
+type AuthConfig struct {
+	Realm    string            `yaml:"realm"`
+	Users    map[string]string `yaml:"users"`    // User names to bcrypt hashes
+	Htpasswd string            `yaml:"htpasswd"` // The path of an htpasswd file
+	// Private maps patterns of repository names to the users allowed to read
+	// the repositories they match, "*" allowing any authenticated user
+	Private map[string][]string `yaml:"private"`
+}
+
 type Config struct {
-	Domain       string                  `yaml:"domain"`
-	GitURL       string                  `yaml:"git-url"`
-	RepoRoot     string                  `yaml:"repo-root"`
-	Debug        bool                    `yaml:"debug"`
-	Descriptions map[string]string       `yaml:"descriptions"`
-	Loggers      map[string]LoggerConfig `yaml:"loggers"`
+	Domain       string                      `yaml:"domain"`
+	GitURL       string                      `yaml:"git-url"`
+	RepoRoot     string                      `yaml:"repo-root"`
+	RepoDepth    int                         `yaml:"repo-depth"`
+	Debug        bool                        `yaml:"debug"`
+	Descriptions map[string]string           `yaml:"descriptions"`
+	Loggers      map[string]LoggerConfig     `yaml:"loggers"`
+	Cache        CacheConfig                 `yaml:"cache"`
+	Listeners    []ListenerConfig            `yaml:"listeners"`
+	Timeouts     TimeoutsConfig              `yaml:"timeouts"`
+	Auth         AuthConfig                  `yaml:"auth"`
+	Repositories map[string]RepositoryConfig `yaml:"repositories"`
+	Metrics      MetricsConfig               `yaml:"metrics"`
+	Health       HealthConfig                `yaml:"health"`
+	Syntax       SyntaxConfig                `yaml:"syntax"`
+	AssetsDir    string                      `yaml:"assets-dir"` // Overrides the bundled templates and static files
+}
+
+// DefaultListeners are used when no listener is set in config files.
+var DefaultListeners = []ListenerConfig{
+	{Address: "localhost:8080"},
+}
+
+// DefaultTimeoutsConfig is used for the timeout options missing from config
+// files.
+var DefaultTimeoutsConfig = TimeoutsConfig{
+	Read:     10 * time.Second,
+	Write:    10 * time.Second,
+	Shutdown: 30 * time.Second,
+}
+
+// DefaultSyntaxConfig is used for the syntax options missing from config
+// files.
+var DefaultSyntaxConfig = SyntaxConfig{
+	Light: "github",
+	Dark:  "ayu-dark",
+}
+
+// DefaultCacheConfig is used for the cache options missing from config files.
+var DefaultCacheConfig = CacheConfig{
+	Repositories: 64,
+	Trees:        1024,
+	Blobs:        32 << 20,
+	LastCommits:  1024,
+	Comparisons:  256,
 }
 
 func NewConfig(path string) (*Config, error) {
@@ -28,12 +133,123 @@ func NewConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
-	config := &Config{}
+	config := &Config{
+		Cache:    DefaultCacheConfig,
+		Timeouts: DefaultTimeoutsConfig,
+		Syntax:   DefaultSyntaxConfig,
+	}
 
 	err = yaml.Unmarshal(bytes, config)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(config.Listeners) == 0 {
+		config.Listeners = DefaultListeners
+	}
+
 	return config, nil
 }
+
+// Validate reports whether the config can be used to serve repositories.
+func (c *Config) Validate() error {
+	file, err := os.Stat(c.RepoRoot)
+	if err != nil {
+		return err
+	}
+
+	if !file.IsDir() {
+		return fmt.Errorf("repo-root is not a directory: %q", c.RepoRoot)
+	}
+
+	if c.AssetsDir != "" {
+		file, err := os.Stat(c.AssetsDir)
+		if err != nil {
+			return err
+		}
+
+		if !file.IsDir() {
+			return fmt.Errorf("assets-dir is not a directory: %q", c.AssetsDir)
+		}
+	}
+
+	if c.RepoDepth < 0 {
+		return fmt.Errorf("repo-depth is negative: %d", c.RepoDepth)
+	}
+
+	if c.Cache.Repositories < 0 || c.Cache.Trees < 0 || c.Cache.Blobs < 0 ||
+		c.Cache.LastCommits < 0 || c.Cache.Comparisons < 0 {
+		return fmt.Errorf("cache sizes cannot be negative: %+v", c.Cache)
+	}
+
+	for _, listener := range c.Listeners {
+		err := listener.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Timeouts.Read < 0 || c.Timeouts.Write < 0 || c.Timeouts.Idle < 0 ||
+		c.Timeouts.Shutdown < 0 {
+		return fmt.Errorf("timeouts cannot be negative: %+v", c.Timeouts)
+	}
+
+	if c.Metrics.Listener != nil {
+		err := c.Metrics.Listener.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for pattern := range c.Auth.Private {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid private repository pattern: %q", pattern)
+		}
+	}
+
+	return nil
+}
+
+// Validate reports whether the listener can be opened.
+func (l *ListenerConfig) Validate() error {
+	if (l.Address == "") == (l.Socket == "") {
+		return fmt.Errorf("listeners need either an address or a socket: %+v", *l)
+	}
+
+	if l.Address != "" && l.SocketMode != "" {
+		return fmt.Errorf("socket-mode is only used by socket listeners: %+v", *l)
+	}
+
+	if l.SocketMode != "" {
+		_, err := l.Mode()
+		if err != nil {
+			return err
+		}
+	}
+
+	if (l.TLSCert == "") != (l.TLSKey == "") {
+		return fmt.Errorf("tls-cert and tls-key must be set together: %+v", *l)
+	}
+
+	return nil
+}
+
+// Mode returns the permissions of the socket, or 0 if they are not set.
+func (l *ListenerConfig) Mode() (os.FileMode, error) {
+	if l.SocketMode == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid socket-mode: %q", l.SocketMode)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// IsTLS reports whether the listener serves HTTPS.
+func (l *ListenerConfig) IsTLS() bool {
+	return l.TLSCert != ""
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestConfig(t *testing.T) {
@@ -36,6 +38,10 @@ func TestConfig(t *testing.T) {
 		t.Errorf("wrong root value: got %v want %v", cfg.RepoRoot, want)
 	}
 
+	if cfg.RepoDepth != 2 {
+		t.Errorf("wrong repo-depth value: got %v want %v", cfg.RepoDepth, 2)
+	}
+
 	if !cfg.Debug {
 		t.Errorf("wrong debug value: got %v want %v", cfg.Debug, true)
 	}
@@ -69,4 +75,143 @@ func TestConfig(t *testing.T) {
 		t.Errorf("wrong router logger mode: got %q want %q",
 			loggerConfig.Mode, want)
 	}
+
+	cache := CacheConfig{
+		Repositories: DefaultCacheConfig.Repositories,
+		Trees:        0,
+		Blobs:        1024,
+		LastCommits:  DefaultCacheConfig.LastCommits,
+		Comparisons:  DefaultCacheConfig.Comparisons,
+	}
+	if cfg.Cache != cache {
+		t.Errorf("wrong cache config: got %+v want %+v", cfg.Cache, cache)
+	}
+
+	listeners := []ListenerConfig{
+		{Address: "localhost:8080"},
+		{Socket: "/run/fudge/fudge.sock", SocketMode: "0660"},
+		{Address: ":8443", TLSCert: "/etc/ssl/fudge.crt",
+			TLSKey: "/etc/ssl/private/fudge.key"},
+	}
+	if len(cfg.Listeners) != len(listeners) {
+		t.Fatalf("wrong number of listeners: got %d want %d",
+			len(cfg.Listeners), len(listeners))
+	}
+	for i, listener := range cfg.Listeners {
+		if listener != listeners[i] {
+			t.Errorf("wrong listener config: got %+v want %+v",
+				listener, listeners[i])
+		}
+	}
+
+	timeouts := TimeoutsConfig{
+		Read:     DefaultTimeoutsConfig.Read,
+		Write:    time.Minute,
+		Shutdown: 5 * time.Second,
+	}
+	if cfg.Timeouts != timeouts {
+		t.Errorf("wrong timeouts config: got %+v want %+v", cfg.Timeouts, timeouts)
+	}
+
+	auth := AuthConfig{
+		Realm: "Example",
+		Users: map[string]string{
+			"alice": "$2y$10$Hx2T6tQvRbuGCdEQ6B.ynuWyfXxpbyYqlP/gvL8nHOWhrnNA3c6ru",
+		},
+		Htpasswd: "/etc/fudge/htpasswd",
+		Private: map[string][]string{
+			"team/*": {"*"},
+			"secret": {"alice"},
+		},
+	}
+	if !reflect.DeepEqual(cfg.Auth, auth) {
+		t.Errorf("wrong auth config: got %+v want %+v", cfg.Auth, auth)
+	}
+
+	repository, ok := cfg.Repositories["simple"]
+	if !ok {
+		t.Fatal("expected a simple repository entry")
+	}
+
+	if repository.Owner != "Jane Doe" || repository.DefaultBranch != "main" ||
+		repository.Hidden == nil || *repository.Hidden {
+		t.Errorf("wrong repository config: got %+v", repository)
+	}
+
+	if !cfg.Metrics.Enable || cfg.Metrics.Listener == nil ||
+		*cfg.Metrics.Listener != (ListenerConfig{Address: "localhost:9100"}) {
+		t.Errorf("wrong metrics config: got %+v", cfg.Metrics)
+	}
+
+	syntax := SyntaxConfig{
+		Light: "solarized",
+		Dark:  DefaultSyntaxConfig.Dark,
+		Styles: map[string]map[string]string{
+			"solarized": {
+				"Background": "bg:#fdf6e3",
+				"Keyword":    "bold #859900",
+			},
+		},
+	}
+	if !reflect.DeepEqual(cfg.Syntax, syntax) {
+		t.Errorf("wrong syntax config: got %+v want %+v", cfg.Syntax, syntax)
+	}
+
+	want = "simple"
+	if cfg.Health.Repository != want {
+		t.Errorf("wrong health repository: got %q want %q",
+			cfg.Health.Repository, want)
+	}
+
+	want = "/etc/fudge/assets"
+	if cfg.AssetsDir != want {
+		t.Errorf("wrong assets-dir value: got %q want %q", cfg.AssetsDir, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		cfg   *Config
+		valid bool
+	}{
+		{&Config{RepoRoot: "testdata"}, true},
+		{&Config{RepoRoot: "testdata", RepoDepth: 3}, true},
+		{&Config{RepoRoot: "testdata/nonexistent"}, false},
+		{&Config{RepoRoot: "testdata/config.yml"}, false},
+		{&Config{RepoRoot: "testdata", RepoDepth: -1}, false},
+		{&Config{RepoRoot: "testdata", Cache: CacheConfig{Blobs: -1}}, false},
+		{&Config{RepoRoot: "testdata", Listeners: []ListenerConfig{
+			{Address: ":8080"},
+			{Socket: "fudge.sock", SocketMode: "0600"},
+			{Address: ":8443", TLSCert: "cert.pem", TLSKey: "key.pem"},
+		}}, true},
+		{&Config{RepoRoot: "testdata", Listeners: []ListenerConfig{{}}}, false},
+		{&Config{RepoRoot: "testdata", Listeners: []ListenerConfig{
+			{Address: ":8080", Socket: "fudge.sock"},
+		}}, false},
+		{&Config{RepoRoot: "testdata", Listeners: []ListenerConfig{
+			{Socket: "fudge.sock", SocketMode: "0999"},
+		}}, false},
+		{&Config{RepoRoot: "testdata", Listeners: []ListenerConfig{
+			{Address: ":8443", TLSCert: "cert.pem"},
+		}}, false},
+		{&Config{RepoRoot: "testdata", Timeouts: TimeoutsConfig{Idle: -1}}, false},
+		{&Config{RepoRoot: "testdata", Metrics: MetricsConfig{
+			Enable:   true,
+			Listener: &ListenerConfig{},
+		}}, false},
+		{&Config{RepoRoot: "testdata", AssetsDir: "testdata"}, true},
+		{&Config{RepoRoot: "testdata", AssetsDir: "testdata/nonexistent"}, false},
+		{&Config{RepoRoot: "testdata", AssetsDir: "testdata/config.yml"}, false},
+		{&Config{RepoRoot: "testdata", Auth: AuthConfig{
+			Private: map[string][]string{"team/[": {"alice"}},
+		}}, false},
+	}
+
+	for _, test := range tests {
+		err := test.cfg.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("wrong validation result for %+v: got %v", test.cfg, err)
+		}
+	}
 }
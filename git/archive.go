@@ -0,0 +1,364 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// The archive formats supported by NewArchive, named after their file
+// extension.
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+var ArchiveFormats = []string{ArchiveTarGz, ArchiveZip}
+
+// archiveWriter writes the entries of an archive in a given format.
+type archiveWriter interface {
+	WriteDir(name string, modTime time.Time) error
+	WriteFile(name string, mode os.FileMode, size int64, modTime time.Time, r io.Reader) error
+	WriteSymlink(name, target string, modTime time.Time) error
+	Close() error
+}
+
+type tarGzWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzWriter(w io.Writer) *tarGzWriter {
+	gz := gzip.NewWriter(w)
+
+	return &tarGzWriter{
+		gz: gz,
+		tw: tar.NewWriter(gz),
+	}
+}
+
+func (w *tarGzWriter) WriteDir(name string, modTime time.Time) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  modTime,
+	})
+}
+
+func (w *tarGzWriter) WriteFile(name string, mode os.FileMode, size int64, modTime time.Time, r io.Reader) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     size,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w.tw, r)
+
+	return err
+}
+
+func (w *tarGzWriter) WriteSymlink(name, target string, modTime time.Time) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     name,
+		Linkname: target,
+		Mode:     0777,
+		ModTime:  modTime,
+	})
+}
+
+func (w *tarGzWriter) Close() error {
+	err := w.tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return w.gz.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func newZipWriter(w io.Writer) *zipWriter {
+	return &zipWriter{
+		zw: zip.NewWriter(w),
+	}
+}
+
+func (w *zipWriter) create(name string, mode os.FileMode, modTime time.Time) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	header.SetMode(mode)
+
+	if mode.IsDir() {
+		header.Method = zip.Store
+	}
+
+	return w.zw.CreateHeader(header)
+}
+
+func (w *zipWriter) WriteDir(name string, modTime time.Time) error {
+	_, err := w.create(name+"/", os.ModeDir|0755, modTime)
+
+	return err
+}
+
+func (w *zipWriter) WriteFile(name string, mode os.FileMode, size int64, modTime time.Time, r io.Reader) error {
+	writer, err := w.create(name, mode, modTime)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, r)
+
+	return err
+}
+
+func (w *zipWriter) WriteSymlink(name, target string, modTime time.Time) error {
+	writer, err := w.create(name, os.ModeSymlink|0777, modTime)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(writer, target)
+
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// getExportMatcher returns a matcher for the attributes found in every
+// .gitattributes file of tree.
+func getExportMatcher(tree *object.Tree) (gitattributes.Matcher, error) {
+	type attributesFile struct {
+		domain     []string
+		attributes []gitattributes.MatchAttribute
+	}
+
+	var files []*attributesFile
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Name != ".gitattributes" || !entry.Mode.IsFile() {
+			continue
+		}
+
+		file, err := tree.TreeEntryFile(&entry)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := file.Reader()
+		if err != nil {
+			return nil, err
+		}
+
+		var domain []string
+		if dir := strings.TrimSuffix(name, entry.Name); dir != "" {
+			domain = strings.Split(strings.TrimSuffix(dir, "/"), "/")
+		}
+
+		attributes, err := gitattributes.ReadAttributes(reader, domain, len(domain) == 0)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &attributesFile{domain, attributes})
+	}
+
+	// Attributes found deeper in the tree take precedence
+	sort.SliceStable(files, func(i, j int) bool {
+		return len(files[i].domain) < len(files[j].domain)
+	})
+
+	var stack []gitattributes.MatchAttribute
+	for _, file := range files {
+		stack = append(stack, file.attributes...)
+	}
+
+	return gitattributes.NewMatcher(stack), nil
+}
+
+// isExportIgnored reports whether the export-ignore attribute is set for path.
+func isExportIgnored(matcher gitattributes.Matcher, path string) bool {
+	results, _ := matcher.Match(strings.Split(path, "/"), []string{"export-ignore"})
+
+	attribute, ok := results["export-ignore"]
+
+	return ok && attribute.IsSet()
+}
+
+// Archive is an archive of the tree of a commit, ready to be written.
+type Archive struct {
+	commit  *object.Commit
+	tree    *object.Tree
+	format  string
+	matcher gitattributes.Matcher
+}
+
+// NewArchive returns an archive of the tree of c in the given format. The
+// .gitattributes files are read beforehand, so that errors are reported before
+// anything is written.
+func NewArchive(c *object.Commit, format string) (*Archive, error) {
+	switch format {
+	case ArchiveTarGz, ArchiveZip:
+	default:
+		return nil, fmt.Errorf("unknown archive format: %q", format)
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := getExportMatcher(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{c, tree, format, matcher}, nil
+}
+
+// Write writes the archive to w, each entry being prefixed with prefix.
+// Entries having the export-ignore attribute set in a .gitattributes file are
+// left out, and files are streamed one at a time to avoid buffering the whole
+// archive in memory.
+func (a *Archive) Write(w io.Writer, prefix string) error {
+	var writer archiveWriter
+	if a.format == ArchiveZip {
+		writer = newZipWriter(w)
+	} else {
+		writer = newTarGzWriter(w)
+	}
+
+	tree, matcher := a.tree, a.matcher
+	modTime := a.commit.Committer.When
+
+	err := writer.WriteDir(strings.TrimSuffix(prefix, "/"), modTime)
+	if err != nil {
+		return err
+	}
+
+	var ignored []string
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		isIgnored := isExportIgnored(matcher, name)
+		for _, dir := range ignored {
+			if strings.HasPrefix(name, dir+"/") {
+				isIgnored = true
+			}
+		}
+
+		if isIgnored {
+			if entry.Mode == filemode.Dir {
+				ignored = append(ignored, name)
+			}
+
+			continue
+		}
+
+		path := prefix + name
+
+		switch entry.Mode {
+		case filemode.Dir:
+			err = writer.WriteDir(path, modTime)
+		case filemode.Regular, filemode.Deprecated, filemode.Executable:
+			err = writeArchiveFile(writer, tree, &entry, path, modTime)
+		case filemode.Symlink:
+			err = writeArchiveSymlink(writer, tree, &entry, path, modTime)
+		default:
+			// Submodules are not part of the archive
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+func writeArchiveFile(writer archiveWriter, tree *object.Tree, entry *object.TreeEntry, path string, modTime time.Time) error {
+	file, err := tree.TreeEntryFile(entry)
+	if err != nil {
+		return err
+	}
+
+	mode, err := entry.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return writer.WriteFile(path, mode, file.Size, modTime, reader)
+}
+
+func writeArchiveSymlink(writer archiveWriter, tree *object.Tree, entry *object.TreeEntry, path string, modTime time.Time) error {
+	file, err := tree.TreeEntryFile(entry)
+	if err != nil {
+		return err
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	target, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return writer.WriteSymlink(path, string(target), modTime)
+}
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ResolveRevision(r, "attributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"python/",
+		"python/.gitattributes",
+		"python/src/",
+		"python/src/hello.py",
+		"python/src/helpers/",
+		"python/src/helpers/__init__.py",
+		"python/src/helpers/helpers.py",
+		"python/src/link.py",
+	}
+
+	for _, format := range ArchiveFormats {
+		archive, err := NewArchive(c, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffer := new(bytes.Buffer)
+
+		err = archive.Write(buffer, "python/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+
+		switch format {
+		case ArchiveTarGz:
+			gz, err := gzip.NewReader(buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tr := tar.NewReader(gz)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got = append(got, header.Name)
+			}
+		case ArchiveZip:
+			zr, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, file := range zr.File {
+				got = append(got, file.Name)
+			}
+		}
+
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("wrong %s archive entries: got %v want %v", format, got, want)
+		}
+	}
+
+	_, err = NewArchive(c, "rar")
+	if err == nil {
+		t.Error("expected an error for an unknown archive format")
+	}
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+)
+
+type BlameLine struct {
+	Hash    string
+	Author  string // The name of the commit author
+	Date    time.Time
+	IsFirst bool // Whether the line starts a run of lines from the same commit
+}
+
+// getBlameParent returns the parent of c the lines of the file found at path
+// are taken from, along with its version of the file, or nil if the file was
+// added by c. A parent with the same version of the file is preferred, so
+// that merges taking the file from another branch are followed, and the first
+// parent is used otherwise.
+func getBlameParent(c *object.Commit, path string, file *object.File) (*object.Commit, *object.File, error) {
+	var parent *object.Commit
+	var parentFile *object.File
+
+	err := c.Parents().ForEach(func(p *object.Commit) error {
+		f, err := p.File(path)
+		if err == object.ErrFileNotFound {
+			f = nil
+		} else if err != nil {
+			return err
+		}
+
+		if f != nil && f.Hash == file.Hash {
+			parent, parentFile = p, f
+			return storer.ErrStop
+		}
+
+		if parent == nil {
+			parent, parentFile = p, f
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if parentFile == nil {
+		return nil, nil, nil
+	}
+
+	return parent, parentFile, nil
+}
+
+// GetBlame returns, for each line of the file found at path in the tree of c,
+// the commit that last modified it. The history is walked from c, and each
+// version of the file is diffed against the one of its parent, so that lines
+// are blamed on the commit they first appear in.
+func GetBlame(c *object.Commit, path string) ([]*BlameLine, error) {
+	file, err := c.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make([]*object.Commit, countLines(contents))
+	remaining := len(owners)
+
+	// The line numbers in the blamed file of the lines of the version being
+	// walked, or -1 for the lines that do not make it to the blamed file
+	origins := make([]int, len(owners))
+	for i := range origins {
+		origins[i] = i
+	}
+
+	for remaining != 0 {
+		parent, parentFile, err := getBlameParent(c, path, file)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent == nil {
+			for _, n := range origins {
+				if n != -1 {
+					owners[n] = c
+				}
+			}
+
+			break
+		}
+
+		if parentFile.Hash == file.Hash {
+			c, file = parent, parentFile
+			continue
+		}
+
+		parentContents, err := parentFile.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		var parentOrigins []int
+		i := 0
+
+		for _, d := range diff.Do(parentContents, contents) {
+			n := countLines(d.Text)
+
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				parentOrigins = append(parentOrigins, origins[i:i+n]...)
+				i += n
+			case diffmatchpatch.DiffInsert:
+				for _, line := range origins[i : i+n] {
+					if line != -1 {
+						owners[line] = c
+						remaining--
+					}
+				}
+				i += n
+			case diffmatchpatch.DiffDelete:
+				for j := 0; j < n; j++ {
+					parentOrigins = append(parentOrigins, -1)
+				}
+			}
+		}
+
+		c, file, contents, origins = parent, parentFile, parentContents, parentOrigins
+	}
+
+	var lines []*BlameLine
+	var previous *object.Commit
+
+	for _, owner := range owners {
+		l := &BlameLine{
+			Hash:    owner.Hash.String(),
+			Author:  owner.Author.Name,
+			Date:    owner.Author.When,
+			IsFirst: previous == nil || owner.Hash != previous.Hash,
+		}
+
+		lines = append(lines, l)
+		previous = owner
+	}
+
+	return lines, nil
+}
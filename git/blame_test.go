@@ -0,0 +1,136 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestGetBlame(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ResolveRevision(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetBlame(c, "README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		hash    string
+		isFirst bool
+	}{
+		{"8018d114b13d3b65862d450cf77189344ac094c1", true},
+		{"8018d114b13d3b65862d450cf77189344ac094c1", false},
+		{"fcd547424101b07adbd1e6cf4a06305342ae8f66", true},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of lines: got %d want %d", len(got), len(want))
+	}
+
+	for i, line := range got {
+		if line.Hash != want[i].hash || line.IsFirst != want[i].isFirst {
+			t.Errorf("wrong blame for line %d: got (%s, %v) want (%s, %v)",
+				i+1, line.Hash, line.IsFirst, want[i].hash, want[i].isFirst)
+		}
+
+		if line.Author != "Jane Doe" {
+			t.Errorf("wrong author for line %d: got %s want %s",
+				i+1, line.Author, "Jane Doe")
+		}
+	}
+}
+
+func TestGetBlameSameSecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All the commits are made in the same second
+	when := time.Unix(1500000000, 0)
+
+	commit := func(files map[string]string) string {
+		for name, contents := range files {
+			err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = worktree.Add(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		hash, err := worktree.Commit("Edit files", &git.CommitOptions{
+			Author: &object.Signature{Name: "Jane Doe", When: when},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return hash.String()
+	}
+
+	first := commit(map[string]string{"a.txt": "line 1\n", "b.txt": "b\n"})
+	second := commit(map[string]string{"a.txt": "line 1\nline 2\n"})
+	third := commit(map[string]string{"a.txt": "line 1\nline 2\nline 3\n"})
+	commit(map[string]string{"b.txt": "b\nb\n"})
+	fifth := commit(map[string]string{"a.txt": "line 0\nline 1\nline 2\nline 3\n"})
+
+	tests := []struct {
+		rev  string
+		want []string
+	}{
+		{second, []string{first, second}},
+		{"master", []string{fifth, first, second, third}},
+	}
+
+	for _, test := range tests {
+		c, err := ResolveRevision(r, test.rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := GetBlame(c, "a.txt")
+		if err != nil {
+			t.Fatalf("could not blame a.txt at %s: %s", test.rev, err)
+		}
+
+		if len(got) != len(test.want) {
+			t.Fatalf("wrong number of lines at %s: got %d want %d",
+				test.rev, len(got), len(test.want))
+		}
+
+		for i, line := range got {
+			if line.Hash != test.want[i] {
+				t.Errorf("wrong blame for line %d at %s: got %s want %s",
+					i+1, test.rev, line.Hash, test.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package git
+
+import (
+	"container/heap"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Comparison holds the changes made on a head revision since it diverged from
+// a base revision.
+type Comparison struct {
+	Base      *object.Commit
+	Head      *object.Commit
+	MergeBase *object.Commit   // The best common ancestor, nil if there is none
+	Commits   []*object.Commit // The commits reachable from head but not from base
+	Truncated bool             // Whether commits were left out past the limit
+}
+
+// CompareCommits compares the head commit to the base one, listing up to
+// limit commits, or all of them if limit is not positive, like
+// `git log base..head`.
+func CompareCommits(base, head *object.Commit, limit int) (*Comparison, error) {
+	mergeBase, commits, err := walkComparison(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := &Comparison{
+		Base:      base,
+		Head:      head,
+		MergeBase: mergeBase,
+		Commits:   commits,
+	}
+
+	if limit > 0 && len(cmp.Commits) > limit {
+		cmp.Commits = cmp.Commits[:limit]
+		cmp.Truncated = true
+	}
+
+	return cmp, nil
+}
+
+// commitQueue is a priority queue of commits, the most recent first.
+type commitQueue []*object.Commit
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*object.Commit)) }
+
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return c
+}
+
+// The flags of the commits walked by walkComparison.
+const (
+	fromHead = 1 << iota // Reachable from head
+	fromBase             // Reachable from base
+	stale                // Reachable from a common ancestor
+)
+
+// walkComparison returns the merge base of base and head, nil if there is
+// none, and the commits reachable from head but not from base, the most recent
+// first. Like Git, both histories are walked at once by commit date, and the
+// walk stops once only the ancestors of common ancestors are left: the
+// histories are only walked down to the merge base instead of entirely.
+func walkComparison(base, head *object.Commit) (*object.Commit, []*object.Commit, error) {
+	flags := map[plumbing.Hash]int{head.Hash: fromHead}
+	flags[base.Hash] |= fromBase
+
+	queue := &commitQueue{head}
+	if base.Hash != head.Hash {
+		queue.Push(base)
+	}
+	heap.Init(queue)
+
+	hasNonStale := func() bool {
+		for _, c := range *queue {
+			if flags[c.Hash]&stale == 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	walked := make(map[plumbing.Hash]bool)
+	var order []*object.Commit
+	var mergeBase *object.Commit
+
+	for hasNonStale() {
+		c := heap.Pop(queue).(*object.Commit)
+		if !walked[c.Hash] {
+			walked[c.Hash] = true
+			order = append(order, c)
+		}
+
+		f := flags[c.Hash]
+		if f&(fromHead|fromBase) == fromHead|fromBase && f&stale == 0 {
+			// The most recent common ancestor is the best one
+			if mergeBase == nil {
+				mergeBase = c
+			}
+
+			f |= stale
+			flags[c.Hash] = f
+		}
+
+		// Commits are walked again when they gain flags, in case commit dates
+		// are out of order
+		err := c.Parents().ForEach(func(parent *object.Commit) error {
+			if flags[parent.Hash]&f != f {
+				flags[parent.Hash] |= f
+				heap.Push(queue, parent)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var commits []*object.Commit
+	for _, c := range order {
+		if flags[c.Hash]&(fromHead|fromBase) == fromHead {
+			commits = append(commits, c)
+		}
+	}
+
+	return mergeBase, commits, nil
+}
+
+// Reload returns a copy of cmp whose commits are read from r, so that a
+// comparison made with another opened copy of the repository can be used.
+func (cmp *Comparison) Reload(r *git.Repository) (*Comparison, error) {
+	read := func(c *object.Commit) (*object.Commit, error) {
+		if c == nil {
+			return nil, nil
+		}
+
+		return r.CommitObject(c.Hash)
+	}
+
+	reloaded := &Comparison{Truncated: cmp.Truncated}
+
+	var err error
+	for _, field := range []struct {
+		from *object.Commit
+		to   **object.Commit
+	}{
+		{cmp.Base, &reloaded.Base},
+		{cmp.Head, &reloaded.Head},
+		{cmp.MergeBase, &reloaded.MergeBase},
+	} {
+		*field.to, err = read(field.from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range cmp.Commits {
+		commit, err := read(c)
+		if err != nil {
+			return nil, err
+		}
+
+		reloaded.Commits = append(reloaded.Commits, commit)
+	}
+
+	return reloaded, nil
+}
+
+// Patch returns the patch between the merge base and the head of cmp, or
+// between its base and head if they have no common ancestor.
+func (cmp *Comparison) Patch() (*object.Patch, error) {
+	from := cmp.MergeBase
+	if from == nil {
+		from = cmp.Base
+	}
+
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := cmp.Head.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return fromTree.Patch(toTree)
+}
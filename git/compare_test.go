@@ -0,0 +1,137 @@
+package git
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// compareRevisions compares the head revision of r to the base one.
+func compareRevisions(t *testing.T, r *git.Repository, base, head string, limit int) *Comparison {
+	baseCommit, err := ResolveRevision(r, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headCommit, err := ResolveRevision(r, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmp, err := CompareCommits(baseCommit, headCommit, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cmp
+}
+
+func TestCompareCommits(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renamed files are reported as deleted and added
+	tests := []struct {
+		base, head string
+		limit      int
+		mergeBase  string
+		commits    []string
+		truncated  bool
+		files      int
+	}{
+		{"v0.1.0", "rename", 10, "8018d114b13d3b65862d450cf77189344ac094c1",
+			[]string{
+				"5f1aa1a7319e4b899e0df62e523ed5b6a3d81a79",
+				"fcd547424101b07adbd1e6cf4a06305342ae8f66",
+				"3c255e3f5a626bc816102e91e2d8f8f94f733ed5",
+			}, false, 4},
+		{"v0.1.0", "rename", 2, "8018d114b13d3b65862d450cf77189344ac094c1",
+			[]string{
+				"5f1aa1a7319e4b899e0df62e523ed5b6a3d81a79",
+				"fcd547424101b07adbd1e6cf4a06305342ae8f66",
+			}, true, 4},
+		{"rename", "attributes", 10, "fcd547424101b07adbd1e6cf4a06305342ae8f66",
+			[]string{"2d7f83c799f68ea450efe9ba4e86e4320f8c0d19"}, false, 2},
+		{"v0.1.0", "rename", 0, "8018d114b13d3b65862d450cf77189344ac094c1",
+			[]string{
+				"5f1aa1a7319e4b899e0df62e523ed5b6a3d81a79",
+				"fcd547424101b07adbd1e6cf4a06305342ae8f66",
+				"3c255e3f5a626bc816102e91e2d8f8f94f733ed5",
+			}, false, 4},
+		{"master", "v0.1.0", 10, "8018d114b13d3b65862d450cf77189344ac094c1",
+			nil, false, 0},
+	}
+
+	for _, test := range tests {
+		cmp := compareRevisions(t, r, test.base, test.head, test.limit)
+
+		if cmp.MergeBase == nil || cmp.MergeBase.Hash.String() != test.mergeBase {
+			t.Errorf("wrong merge base for %s...%s: got %v want %s",
+				test.base, test.head, cmp.MergeBase, test.mergeBase)
+		}
+
+		if len(cmp.Commits) != len(test.commits) {
+			t.Fatalf("wrong number of commits for %s...%s: got %d want %d",
+				test.base, test.head, len(cmp.Commits), len(test.commits))
+		}
+
+		for i, c := range cmp.Commits {
+			if c.Hash.String() != test.commits[i] {
+				t.Errorf("wrong commit %d for %s...%s: got %s want %s",
+					i, test.base, test.head, c.Hash, test.commits[i])
+			}
+		}
+
+		if cmp.Truncated != test.truncated {
+			t.Errorf("wrong truncated value for %s...%s: got %v want %v",
+				test.base, test.head, cmp.Truncated, test.truncated)
+		}
+
+		patch, err := cmp.Patch()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(patch.FilePatches()) != test.files {
+			t.Errorf("wrong number of changed files for %s...%s: got %d want %d",
+				test.base, test.head, len(patch.FilePatches()), test.files)
+		}
+	}
+}
+
+func TestReloadComparison(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmp := compareRevisions(t, r, "v0.1.0", "rename", 2)
+
+	other, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := cmp.Reload(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.Base.Hash != cmp.Base.Hash || reloaded.Head.Hash != cmp.Head.Hash ||
+		reloaded.MergeBase.Hash != cmp.MergeBase.Hash || !reloaded.Truncated {
+		t.Errorf("wrong reloaded comparison: got %+v want %+v", reloaded, cmp)
+	}
+
+	if len(reloaded.Commits) != len(cmp.Commits) {
+		t.Fatalf("wrong number of reloaded commits: got %d want %d",
+			len(reloaded.Commits), len(cmp.Commits))
+	}
+
+	for i, c := range reloaded.Commits {
+		if c.Hash != cmp.Commits[i].Hash || c == cmp.Commits[i] {
+			t.Errorf("commit %d was not reloaded: %s", i, c.Hash)
+		}
+	}
+}
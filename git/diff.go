@@ -0,0 +1,122 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	fdiff "gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type FileDiff struct {
+	From      string // The file path before the change, empty if it was added
+	To        string // The file path after the change, empty if it was deleted
+	IsBinary  bool
+	Additions int
+	Deletions int
+	Patch     string // The file unified diff
+}
+
+// Name returns the path of the file after the change, or before the change if
+// the file was deleted.
+func (d *FileDiff) Name() string {
+	if d.To == "" {
+		return d.From
+	}
+
+	return d.To
+}
+
+// filePatch wraps a single file patch so that it can be encoded on its own.
+type filePatch struct {
+	fdiff.FilePatch
+}
+
+func (p filePatch) FilePatches() []fdiff.FilePatch {
+	return []fdiff.FilePatch{p.FilePatch}
+}
+
+func (p filePatch) Message() string {
+	return ""
+}
+
+// countLines returns the number of lines in s, counting a trailing line
+// without a newline character.
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+
+	return n
+}
+
+// GetCommitPatch returns the patch between the first parent of c and c. If c
+// is a root commit, the patch is computed against the empty tree.
+func GetCommitPatch(c *object.Commit) (*object.Patch, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := &object.Tree{}
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parentTree.Patch(tree)
+}
+
+// GetFileDiffs splits patch into one unified diff per changed file.
+func GetFileDiffs(patch *object.Patch) ([]*FileDiff, error) {
+	var diffs []*FileDiff
+
+	for _, fp := range patch.FilePatches() {
+		d := &FileDiff{
+			IsBinary: fp.IsBinary(),
+		}
+
+		from, to := fp.Files()
+		if from != nil {
+			d.From = from.Path()
+		}
+		if to != nil {
+			d.To = to.Path()
+		}
+
+		for _, chunk := range fp.Chunks() {
+			switch chunk.Type() {
+			case fdiff.Add:
+				d.Additions += countLines(chunk.Content())
+			case fdiff.Delete:
+				d.Deletions += countLines(chunk.Content())
+			}
+		}
+
+		buffer := new(bytes.Buffer)
+		encoder := fdiff.NewUnifiedEncoder(buffer, fdiff.DefaultContextLines)
+
+		err := encoder.Encode(filePatch{fp})
+		if err != nil {
+			return nil, err
+		}
+
+		d.Patch = buffer.String()
+
+		diffs = append(diffs, d)
+	}
+
+	return diffs, nil
+}
@@ -0,0 +1,69 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetFileDiffs(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rev   string
+		diffs []FileDiff
+	}{
+		{"8018d114b13d3b65862d450cf77189344ac094c1", []FileDiff{
+			{To: "README.md", Additions: 3},
+			{To: "src/hello.py", Additions: 1},
+			{To: "src/helpers/__init__.py"},
+			{To: "src/helpers/helpers.py", Additions: 2},
+		}},
+		{"fcd547424101b07adbd1e6cf4a06305342ae8f66", []FileDiff{
+			{From: "README.md", To: "README.md", Additions: 1, Deletions: 1},
+		}},
+	}
+
+	for _, test := range tests {
+		c, err := ResolveRevision(r, test.rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patch, err := GetCommitPatch(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := GetFileDiffs(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(test.diffs) {
+			t.Fatalf("wrong number of diffs for %s: got %d want %d",
+				test.rev, len(got), len(test.diffs))
+		}
+
+		for i, diff := range got {
+			want := test.diffs[i]
+
+			if diff.From != want.From || diff.To != want.To {
+				t.Errorf("wrong diff paths: got (%q, %q) want (%q, %q)",
+					diff.From, diff.To, want.From, want.To)
+			}
+
+			if diff.Additions != want.Additions || diff.Deletions != want.Deletions {
+				t.Errorf("wrong diff stats for %s: got (+%d, -%d) want (+%d, -%d)",
+					diff.Name(), diff.Additions, diff.Deletions,
+					want.Additions, want.Deletions)
+			}
+
+			if !strings.HasPrefix(diff.Patch, "diff --git a/"+diff.Name()) {
+				t.Errorf("wrong diff header for %s: %q", diff.Name(), diff.Patch)
+			}
+		}
+	}
+}
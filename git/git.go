@@ -4,16 +4,22 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
 
 type Blob struct {
+	Hash     plumbing.Hash
 	Name     string
 	IsBinary bool
 	Size     string // The blob humanized size
@@ -26,6 +32,24 @@ type TreeObject struct {
 	Size   string // The object humanized size
 }
 
+type CommitsOptions struct {
+	After  plumbing.Hash // If set, only list the commits following this one
+	Before plumbing.Hash // If set, only list the commits preceding this one
+	Limit  int           // The maximum number of commits to list
+	Path   string        // If set, only list the commits touching this path
+}
+
+type CommitPage struct {
+	Commits []*object.Commit
+	Prev    string // The cursor of the previous page, empty if there is none
+	Next    string // The cursor of the next page, empty if there is none
+}
+
+type Ref struct {
+	Name  string // The reference short name, e.g. "master" or "v1.0.0"
+	IsTag bool
+}
+
 func isNotCandidate(path string) bool {
 	file, err := os.Stat(path)
 	isRegularFile := !os.IsNotExist(err) && !file.IsDir()
@@ -33,30 +57,112 @@ func isNotCandidate(path string) bool {
 	return isRegularFile || os.IsNotExist(err)
 }
 
-// OpenRepository opens a Git repository from the given root path and dirname.
-// If strict is set to false, OpenRepository will try to open dirname first,
-// then dirname with a ".git" suffix.
-func OpenRepository(root, dirname string, strict bool) (*git.Repository, error) {
+// FindRepository returns the path of the Git repository candidate found in
+// the given root path and dirname. If strict is set to false, FindRepository
+// will look for dirname first, then dirname with a ".git" suffix.
+func FindRepository(root, dirname string, strict bool) (string, error) {
+	// Namespaced names must not be used to escape root
+	for _, part := range strings.Split(dirname, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", git.ErrRepositoryNotExists
+		}
+	}
+
 	path := filepath.Join(root, dirname)
 
 	if isNotCandidate(path) {
 		if strict {
-			return nil, git.ErrRepositoryNotExists
+			return "", git.ErrRepositoryNotExists
 		}
 
 		path = filepath.Join(root, dirname+".git")
 		if isNotCandidate(path) {
-			return nil, git.ErrRepositoryNotExists
+			return "", git.ErrRepositoryNotExists
 		}
 	}
 
+	return path, nil
+}
+
+// OpenRepository opens a Git repository from the given root path and dirname.
+// If strict is set to false, OpenRepository will try to open dirname first,
+// then dirname with a ".git" suffix.
+func OpenRepository(root, dirname string, strict bool) (*git.Repository, error) {
+	path, err := FindRepository(root, dirname, strict)
+	if err != nil {
+		return nil, err
+	}
+
 	repository, err := git.PlainOpen(path)
 
 	return repository, err
 }
 
-func GetRepositoryNames(root string) ([]string, error) {
-	files, err := ioutil.ReadDir(root)
+// getGitDir returns the Git directory of the repository found at path, which
+// is the .git subdirectory of non-bare repositories.
+func getGitDir(path string) string {
+	gitDir := filepath.Join(path, git.GitDirName)
+	if file, err := os.Stat(gitDir); err == nil && file.IsDir() {
+		return gitDir
+	}
+
+	return path
+}
+
+// GetRefsModTime returns the latest modification time of the HEAD, the
+// packed-refs file and the loose refs of the repository found at path. As Git
+// updates refs by renaming lock files, this time changes whenever a ref is
+// created, updated or deleted.
+func GetRefsModTime(path string) (time.Time, error) {
+	path = getGitDir(path)
+
+	var modTime time.Time
+
+	update := func(file os.FileInfo) {
+		if file.ModTime().After(modTime) {
+			modTime = file.ModTime()
+		}
+	}
+
+	for _, name := range []string{"HEAD", "packed-refs"} {
+		file, err := os.Stat(filepath.Join(path, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		update(file)
+	}
+
+	err := filepath.Walk(filepath.Join(path, "refs"), func(p string, file os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		update(file)
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return time.Time{}, err
+	}
+
+	return modTime, nil
+}
+
+// GetRepositoryNames returns the names of the Git repositories found in root.
+// Subdirectories which are not repositories are searched too, as namespaces,
+// as long as they are less than depth levels deep: with a depth of 1 or less,
+// only the repositories directly in root are listed. The names of namespaced
+// repositories are their slash-separated path relative to root.
+func GetRepositoryNames(root string, depth int) ([]string, error) {
+	return getRepositoryNames(root, "", depth)
+}
+
+func getRepositoryNames(root, namespace string, depth int) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(root, namespace))
 	if err != nil {
 		return nil, err
 	}
@@ -68,15 +174,27 @@ func GetRepositoryNames(root string) ([]string, error) {
 			continue
 		}
 
-		_, err := OpenRepository(root, file.Name(), true)
+		dirname := path.Join(namespace, file.Name())
+
+		_, err := OpenRepository(root, dirname, true)
 		if err == git.ErrRepositoryNotExists {
+			if depth <= 1 {
+				continue
+			}
+
+			nested, err := getRepositoryNames(root, dirname, depth-1)
+			if err != nil {
+				return nil, err
+			}
+
+			names = append(names, nested...)
 			continue
 		}
 		if err != nil {
 			return nil, err
 		}
 
-		name := strings.TrimSuffix(file.Name(), ".git")
+		name := strings.TrimSuffix(dirname, ".git")
 
 		names = append(names, name)
 	}
@@ -84,57 +202,322 @@ func GetRepositoryNames(root string) ([]string, error) {
 	return names, nil
 }
 
-func GetRepositoryCommits(r *git.Repository) ([]*object.Commit, error) {
-	iter, err := r.Log(&git.LogOptions{})
+// ResolveRevision returns the commit pointed to by rev, which can be a branch,
+// a tag or a commit hash. If rev is empty, ResolveRevision resolves HEAD.
+func ResolveRevision(r *git.Repository, rev string) (*object.Commit, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if isRevisionNotFound(err) {
+		return nil, plumbing.ErrReferenceNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var commits []*object.Commit
+	commit, err := r.CommitObject(*hash)
+	if isRevisionNotFound(err) {
+		return nil, plumbing.ErrReferenceNotFound
+	}
 
-	err = iter.ForEach(func(c *object.Commit) error {
-		// XXX
-		c.Message = strings.Split(c.Message, "\n")[0]
-		commits = append(commits, c)
+	return commit, err
+}
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+// isRevisionNotFound returns whether err means that a revision does not exist,
+// as opposed to the repository failing to be read. Malformed revisions and
+// ancestors past the root commit do not exist either.
+func isRevisionNotFound(err error) bool {
+	switch err {
+	case nil:
+		return false
+	case plumbing.ErrReferenceNotFound, plumbing.ErrObjectNotFound, io.EOF:
+		return true
 	}
 
-	return commits, nil
+	// The type of the revision parser errors is internal to go-git
+	return reflect.TypeOf(err).String() == "*revision.ErrInvalidRevision"
 }
 
-func GetRepositoryLastCommit(r *git.Repository) (*object.Commit, error) {
+// SplitRevision splits spec, a revision optionally followed by a slash and a
+// path, into its revision and path parts. As revisions can contain slashes
+// (e.g. "release/1.0"), the shortest leading part of spec resolving to a
+// commit is used as the revision.
+func SplitRevision(r *git.Repository, spec string) (string, string, *object.Commit, error) {
+	parts := strings.Split(spec, "/")
+
+	for i := 1; i <= len(parts); i++ {
+		rev := strings.Join(parts[:i], "/")
+		if rev == "" {
+			continue
+		}
+
+		commit, err := ResolveRevision(r, rev)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		path := strings.Join(parts[i:], "/")
+
+		return rev, path, commit, nil
+	}
+
+	return "", "", nil, plumbing.ErrReferenceNotFound
+}
+
+// GetDefaultRevision returns the name of the branch pointed to by HEAD, or the
+// hash of the commit it points to if HEAD is detached.
+func GetDefaultRevision(r *git.Repository) (string, error) {
 	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+
+	if head.Name().IsBranch() {
+		return head.Name().Short(), nil
+	}
+
+	return head.Hash().String(), nil
+}
+
+// GetRepositoryRefs returns the branches of the repository followed by its
+// tags, each sorted by name.
+func GetRepositoryRefs(r *git.Repository) ([]*Ref, error) {
+	branches, err := r.Branches()
 	if err != nil {
 		return nil, err
 	}
 
-	commit, err := r.CommitObject(head.Hash())
+	tags, err := r.Tags()
 	if err != nil {
 		return nil, err
 	}
 
-	// XXX
-	commit.Message = strings.Split(commit.Message, "\n")[0]
+	var refs []*Ref
+
+	for _, iter := range []storer.ReferenceIter{branches, tags} {
+		var names []string
+
+		err = iter.ForEach(func(ref *plumbing.Reference) error {
+			names = append(names, ref.Name().Short())
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			ref := &Ref{
+				Name:  name,
+				IsTag: iter == tags,
+			}
+
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs, nil
+}
+
+// getEntryHash returns the hash of the object found at path in the tree of c,
+// or the zero hash if there is none.
+func getEntryHash(c *object.Commit, path string) (plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if path == "" {
+		return tree.Hash, nil
+	}
+
+	entry, err := tree.FindEntry(path)
+	if err == object.ErrDirectoryNotFound || err == object.ErrEntryNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
 
-	return commit, nil
+	return entry.Hash, nil
 }
 
-func GetRepositoryTree(r *git.Repository, path string) (*object.Tree, error) {
-	head, err := r.Head()
+// findRenameSource returns the path of an object having the given hash in the
+// tree of parent but not in the tree of c, or an empty string if there is
+// none.
+func findRenameSource(parent, c *object.Commit, hash plumbing.Hash) (string, error) {
+	tree, err := parent.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if entry.Hash != hash {
+			continue
+		}
+
+		current, err := getEntryHash(c, name)
+		if err != nil {
+			return "", err
+		}
+
+		if current.IsZero() {
+			return name, nil
+		}
+	}
+}
+
+// pathFilter selects the commits touching a path, comparing each commit to
+// its first parent. When the path was renamed without being modified, the
+// filter follows its previous name.
+type pathFilter struct {
+	path string
+}
+
+func (f *pathFilter) touches(c *object.Commit) (bool, error) {
+	hash, err := getEntryHash(c, f.path)
+	if err != nil {
+		return false, err
+	}
+
+	if c.NumParents() == 0 {
+		return !hash.IsZero(), nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return false, err
+	}
+
+	parentHash, err := getEntryHash(parent, f.path)
+	if err != nil {
+		return false, err
+	}
+
+	if hash == parentHash {
+		return false, nil
+	}
+
+	if parentHash.IsZero() && !hash.IsZero() {
+		source, err := findRenameSource(parent, c, hash)
+		if err != nil {
+			return false, err
+		}
+
+		if source != "" {
+			f.path = source
+		}
+	}
+
+	return true, nil
+}
+
+// GetRepositoryCommits returns a page of the commits reachable from c. The
+// history is only walked until the page is filled.
+func GetRepositoryCommits(r *git.Repository, c *object.Commit, opts *CommitsOptions) (*CommitPage, error) {
+	iter, err := r.Log(&git.LogOptions{From: c.Hash})
 	if err != nil {
 		return nil, err
 	}
+	defer iter.Close()
 
-	commit, err := r.CommitObject(head.Hash())
+	var commits []*object.Commit
+
+	foundAfter := opts.After.IsZero()
+	foundBefore := opts.Before.IsZero()
+	hasPrev := false
+	hasNext := false
+
+	filter := &pathFilter{path: opts.Path}
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		if opts.Path != "" {
+			touches, err := filter.touches(c)
+			if err != nil {
+				return err
+			}
+
+			if !touches {
+				return nil
+			}
+		}
+
+		if !foundAfter {
+			foundAfter = c.Hash == opts.After
+			hasPrev = true
+			return nil
+		}
+
+		if !opts.Before.IsZero() {
+			if c.Hash == opts.Before {
+				foundBefore = true
+				hasNext = true
+				return storer.ErrStop
+			}
+
+			// Only keep the last commits preceding the Before commit
+			commits = append(commits, c)
+			if len(commits) > opts.Limit {
+				commits = commits[1:]
+				hasPrev = true
+			}
+
+			return nil
+		}
+
+		if len(commits) == opts.Limit {
+			hasNext = true
+			return storer.ErrStop
+		}
+
+		commits = append(commits, c)
+
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	tree, err := commit.Tree()
+	if !foundAfter || !foundBefore {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	page := &CommitPage{
+		Commits: commits,
+	}
+
+	if len(commits) != 0 {
+		if hasPrev {
+			page.Prev = commits[0].Hash.String()
+		}
+
+		if hasNext {
+			page.Next = commits[len(commits)-1].Hash.String()
+		}
+	}
+
+	return page, nil
+}
+
+func GetRepositoryTree(c *object.Commit, path string) (*object.Tree, error) {
+	tree, err := c.Tree()
 	if err != nil {
 		return nil, err
 	}
@@ -149,13 +532,13 @@ func GetRepositoryTree(r *git.Repository, path string) (*object.Tree, error) {
 	return tree, nil
 }
 
-func GetRepositoryBlob(r *git.Repository, path string) (*Blob, error) {
+func GetRepositoryBlob(c *object.Commit, path string) (*Blob, error) {
 	dir := filepath.Dir(path)
 	if dir == "." {
 		dir = ""
 	}
 
-	tree, err := GetRepositoryTree(r, dir)
+	tree, err := GetRepositoryTree(c, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -177,6 +560,7 @@ func GetRepositoryBlob(r *git.Repository, path string) (*Blob, error) {
 	}
 
 	blob := &Blob{
+		Hash:     file.Hash,
 		Name:     file.Name,
 		IsBinary: isBinary,
 		Size:     humanize.Bytes(uint64(file.Blob.Size)),
@@ -229,3 +613,25 @@ func GetTreeObjects(tree *object.Tree) ([]*TreeObject, error) {
 
 	return objects, nil
 }
+
+// readmeNames lists the supported README filenames by order of preference.
+var readmeNames = []string{
+	"README.md",
+	"README.markdown",
+	"README",
+	"README.txt",
+}
+
+// GetReadmeName returns the name of the README file found in objects, or an
+// empty string if there is none. Names are compared case-insensitively.
+func GetReadmeName(objects []*TreeObject) string {
+	for _, name := range readmeNames {
+		for _, o := range objects {
+			if o.IsFile && strings.EqualFold(o.Name, name) {
+				return o.Name
+			}
+		}
+	}
+
+	return ""
+}
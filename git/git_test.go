@@ -1,9 +1,15 @@
 package git
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
@@ -19,6 +25,10 @@ func TestOpenRepository(t *testing.T) {
 		{"testdata/regular_file_with_repo", "test", true, git.ErrRepositoryNotExists},
 		{"testdata/regular_file_with_repo", "test.git", true, nil},
 		{"testdata/regular_file_with_repo", "test", false, nil},
+		{"testdata/repositories", "team/project", false, nil},
+		{"testdata/repositories", "team/project", true, git.ErrRepositoryNotExists},
+		{"testdata/repositories/team", "../normal", false, git.ErrRepositoryNotExists},
+		{"testdata/repositories", "team//project", false, git.ErrRepositoryNotExists},
 	}
 
 	for _, test := range tests {
@@ -30,21 +40,76 @@ func TestOpenRepository(t *testing.T) {
 	}
 }
 
-func TestGetRepositoryNames(t *testing.T) {
-	got, err := GetRepositoryNames("testdata/repositories")
+func TestGetRefsModTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer os.RemoveAll(dir)
 
-	want := []string{"normal", "suffix"}
+	_, err = git.PlainInit(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if len(got) != len(want) {
-		t.Fatalf("wrong number of repositories: got %d want %d", len(got), len(want))
+	before, err := GetRefsModTime(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before.IsZero() {
+		t.Fatal("expected a modification time")
+	}
+
+	path := filepath.Join(dir, "refs", "heads", "master")
+	err = ioutil.WriteFile(path, []byte(plumbing.ZeroHash.String()+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := before.Add(time.Hour)
+	err = os.Chtimes(path, want, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetRefsModTime(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Equal(want) {
+		t.Errorf("wrong modification time: got %v want %v", got, want)
+	}
+}
+
+func TestGetRepositoryNames(t *testing.T) {
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{0, []string{"normal", "suffix"}},
+		{1, []string{"normal", "suffix"}},
+		{2, []string{"normal", "suffix", "team/project"}},
+		{3, []string{"normal", "suffix", "team/nested/deep", "team/project"}},
 	}
 
-	for i, name := range got {
-		if name != want[i] {
-			t.Errorf("wrong repository name: got %s want %s", name, want[i])
+	for _, test := range tests {
+		got, err := GetRepositoryNames("testdata/repositories", test.depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(test.want) {
+			t.Fatalf("wrong number of repositories with depth %d: got %d want %d",
+				test.depth, len(got), len(test.want))
+		}
+
+		for i, name := range got {
+			if name != test.want[i] {
+				t.Errorf("wrong repository name with depth %d: got %s want %s",
+					test.depth, name, test.want[i])
+			}
 		}
 	}
 }
@@ -55,11 +120,18 @@ func TestGetRepositoryCommits(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got, err := GetRepositoryCommits(r)
+	c, err := ResolveRevision(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := GetRepositoryCommits(r, c, &CommitsOptions{Limit: 50})
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	got := page.Commits
+
 	want := []struct {
 		name    string
 		when    string
@@ -85,45 +157,285 @@ func TestGetRepositoryCommits(t *testing.T) {
 			t.Errorf("wrong commit author when: got %s want %s", when, want[i].when)
 		}
 
-		if commit.Message != want[i].message {
+		message := strings.Split(commit.Message, "\n")[0]
+		if message != want[i].message {
 			t.Errorf("wrong commit message: got %s want %s",
-				commit.Message, want[i].message)
+				message, want[i].message)
 		}
 	}
 }
 
-func TestGetRepositoryLastCommit(t *testing.T) {
+func TestGetRepositoryCommitsPage(t *testing.T) {
 	r, err := OpenRepository("testdata/repository", "python", true)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	got, err := GetRepositoryLastCommit(r)
+	c, err := ResolveRevision(r, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	want := struct {
-		name    string
-		when    string
+	first := "fcd547424101b07adbd1e6cf4a06305342ae8f66"
+	second := "3c255e3f5a626bc816102e91e2d8f8f94f733ed5"
+	third := "8018d114b13d3b65862d450cf77189344ac094c1"
+
+	tests := []struct {
+		opts    CommitsOptions
+		commits []string
+		prev    string
+		next    string
+		err     error
+	}{
+		{CommitsOptions{Limit: 1}, []string{first}, "", first, nil},
+		{CommitsOptions{Limit: 3}, []string{first, second, third}, "", "", nil},
+		{CommitsOptions{After: plumbing.NewHash(first), Limit: 1},
+			[]string{second}, second, second, nil},
+		{CommitsOptions{After: plumbing.NewHash(second), Limit: 2},
+			[]string{third}, third, "", nil},
+		{CommitsOptions{Before: plumbing.NewHash(third), Limit: 1},
+			[]string{second}, second, second, nil},
+		{CommitsOptions{Before: plumbing.NewHash(second), Limit: 1},
+			[]string{first}, "", first, nil},
+		{CommitsOptions{After: plumbing.NewHash("0123"), Limit: 1},
+			nil, "", "", plumbing.ErrObjectNotFound},
+	}
+
+	for i, test := range tests {
+		page, err := GetRepositoryCommits(r, c, &test.opts)
+		if err != test.err {
+			t.Errorf("wrong error for test %d: got %v want %v", i, err, test.err)
+		}
+		if err != nil {
+			continue
+		}
+
+		var commits []string
+		for _, commit := range page.Commits {
+			commits = append(commits, commit.Hash.String())
+		}
+
+		if strings.Join(commits, " ") != strings.Join(test.commits, " ") {
+			t.Errorf("wrong commits for test %d: got %v want %v",
+				i, commits, test.commits)
+		}
+
+		if page.Prev != test.prev || page.Next != test.next {
+			t.Errorf("wrong cursors for test %d: got (%q, %q) want (%q, %q)",
+				i, page.Prev, page.Next, test.prev, test.next)
+		}
+	}
+}
+
+func TestGetRepositoryCommitsPath(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ResolveRevision(r, "rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		messages []string
+	}{
+		{"README.md", []string{"Edit README.md", "Initial commit"}},
+		{"tests", []string{"Add tests"}},
+		{"src", []string{"Rename hello.py to main.py", "Initial commit"}},
+		{"src/main.py", []string{"Rename hello.py to main.py", "Initial commit"}},
+		{"src/hello.py", []string{"Rename hello.py to main.py", "Initial commit"}},
+		{"nonexistent", nil},
+	}
+
+	for _, test := range tests {
+		page, err := GetRepositoryCommits(r, c, &CommitsOptions{
+			Limit: 50,
+			Path:  test.path,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var messages []string
+		for _, commit := range page.Commits {
+			messages = append(messages, strings.Split(commit.Message, "\n")[0])
+		}
+
+		if strings.Join(messages, ", ") != strings.Join(test.messages, ", ") {
+			t.Errorf("wrong commits touching %s: got %v want %v",
+				test.path, messages, test.messages)
+		}
+	}
+}
+
+func TestResolveRevision(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rev     string
 		message string
+		err     error
 	}{
-		"Jane Doe",
-		"Oct 24, 2019",
-		"Edit README.md",
+		{"", "Edit README.md", nil},
+		{"master", "Edit README.md", nil},
+		{"release/0.1", "Add tests", nil},
+		{"8018d114b13d3b65862d450cf77189344ac094c1", "Initial commit", nil},
+		{"nonexistent", "", plumbing.ErrReferenceNotFound},
+		{"master~100", "", plumbing.ErrReferenceNotFound},
+		{"docs/file name.md", "", plumbing.ErrReferenceNotFound},
 	}
 
-	if got.Author.Name != want.name {
-		t.Errorf("wrong commit author name: got %s want %s", got.Author.Name, want.name)
+	for _, test := range tests {
+		got, err := ResolveRevision(r, test.rev)
+		if err != test.err {
+			t.Errorf("wrong error when resolving %q: got %v want %v",
+				test.rev, err, test.err)
+		}
+		if err != nil {
+			continue
+		}
+
+		message := strings.Split(got.Message, "\n")[0]
+		if message != test.message {
+			t.Errorf("wrong commit message for %q: got %s want %s",
+				test.rev, message, test.message)
+		}
 	}
+}
 
-	when := got.Author.When.Format("Jan 2, 2006")
-	if when != want.when {
-		t.Errorf("wrong commit author when: got %s want %s", when, want.when)
+func TestResolveRevisionCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first plumbing.Hash
+	for i := 0; i < 2; i++ {
+		hash, err := worktree.Commit("Commit", &git.CommitOptions{
+			Author: &object.Signature{Name: "fudge", When: time.Unix(int64(i), 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			first = hash
+		}
+	}
+
+	// The parent of master cannot be decompressed anymore
+	hex := first.String()
+	path := filepath.Join(dir, ".git", "objects", hex[:2], hex[2:])
+	err = os.Chmod(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, []byte("corrupt"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ResolveRevision(r, "master~1")
+	if err == nil || err == plumbing.ErrReferenceNotFound {
+		t.Errorf("expected the read error to be returned, got %v", err)
+	}
+}
+
+func TestSplitRevision(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec string
+		rev  string
+		path string
+		err  error
+	}{
+		{"master", "master", "", nil},
+		{"master/src/hello.py", "master", "src/hello.py", nil},
+		{"release/0.1/tests", "release/0.1", "tests", nil},
+		{"release/src", "", "", plumbing.ErrReferenceNotFound},
+		{"", "", "", plumbing.ErrReferenceNotFound},
+	}
+
+	for _, test := range tests {
+		rev, path, _, err := SplitRevision(r, test.spec)
+		if err != test.err {
+			t.Errorf("wrong error when splitting %q: got %v want %v",
+				test.spec, err, test.err)
+		}
+
+		if rev != test.rev || path != test.path {
+			t.Errorf("wrong split of %q: got (%q, %q) want (%q, %q)",
+				test.spec, rev, path, test.rev, test.path)
+		}
+	}
+}
+
+func TestGetDefaultRevision(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetDefaultRevision(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "master"
+	if got != want {
+		t.Errorf("wrong default revision: got %s want %s", got, want)
+	}
+}
+
+func TestGetRepositoryRefs(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetRepositoryRefs(r)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if got.Message != want.message {
-		t.Errorf("wrong commit message: got %s want %s", got.Message, want.message)
+	want := []Ref{
+		{"attributes", false},
+		{"master", false},
+		{"release/0.1", false},
+		{"rename", false},
+		{"v0.1.0", true},
+		{"v0.2.0", true},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of refs: got %d want %d", len(got), len(want))
+	}
+
+	for i, ref := range got {
+		if *ref != want[i] {
+			t.Errorf("wrong ref: got %v want %v", *ref, want[i])
+		}
 	}
 }
 
@@ -133,6 +445,11 @@ func TestGetRepositoryTree(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	c, err := ResolveRevision(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		path string
 		err  error
@@ -146,7 +463,7 @@ func TestGetRepositoryTree(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err = GetRepositoryTree(r, test.path)
+		_, err = GetRepositoryTree(c, test.path)
 		if err != test.err {
 			t.Errorf("wrong error when getting tree %s: got %v want %v",
 				test.path, err, test.err)
@@ -160,6 +477,11 @@ func TestGetRepositoryBlob(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	c, err := ResolveRevision(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		path string
 		err  error
@@ -171,7 +493,7 @@ func TestGetRepositoryBlob(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err = GetRepositoryBlob(r, test.path)
+		_, err = GetRepositoryBlob(c, test.path)
 		if err != test.err {
 			t.Errorf("wrong error when getting blob %s: got %v want %v",
 				test.path, err, test.err)
@@ -185,7 +507,12 @@ func TestGetTreeObjects(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	tree, err := GetRepositoryTree(r, "")
+	c, err := ResolveRevision(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := GetRepositoryTree(c, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -219,3 +546,26 @@ func TestGetTreeObjects(t *testing.T) {
 		}
 	}
 }
+
+func TestGetReadmeName(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"main.go"}, ""},
+		{[]string{"README.txt", "readme.md"}, "readme.md"},
+		{[]string{"Readme", "README.txt"}, "Readme"},
+	}
+
+	for _, test := range tests {
+		var objects []*TreeObject
+		for _, name := range test.names {
+			objects = append(objects, &TreeObject{Name: name, IsFile: true})
+		}
+
+		got := GetReadmeName(objects)
+		if got != test.want {
+			t.Errorf("wrong README name in %v: got %q want %q", test.names, got, test.want)
+		}
+	}
+}
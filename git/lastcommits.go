@@ -0,0 +1,122 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+type LastCommits struct {
+	Tree    *object.Commit            // The last commit touching the tree itself
+	Entries map[string]*object.Commit // The last commit touching each entry, by name
+}
+
+// getTreeEntries returns the hash of each entry of the tree found at path in
+// the tree of c, or nil if there is none.
+func getTreeEntries(c *object.Commit, path string) (map[string]plumbing.Hash, error) {
+	tree, err := GetRepositoryTree(c, path)
+	if err == object.ErrDirectoryNotFound || err == object.ErrEntryNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]plumbing.Hash)
+	for _, entry := range tree.Entries {
+		entries[entry.Name] = entry.Hash
+	}
+
+	return entries, nil
+}
+
+// GetLastCommits returns the last commit reachable from c touching the tree
+// found at path, and the last one touching each of its entries. Like
+// GetRepositoryCommits, each commit is compared to its first parent, and the
+// history is walked once for all entries, only until each of them is found.
+func GetLastCommits(r *git.Repository, c *object.Commit, path string) (*LastCommits, error) {
+	current, err := getTreeEntries(c, path)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, object.ErrDirectoryNotFound
+	}
+
+	iter, err := r.Log(&git.LogOptions{From: c.Hash})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	last := &LastCommits{
+		Entries: make(map[string]*object.Commit),
+	}
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		hash, err := getEntryHash(c, path)
+		if err != nil {
+			return err
+		}
+
+		var parentHash plumbing.Hash
+		var parent *object.Commit
+
+		if c.NumParents() != 0 {
+			parent, err = c.Parent(0)
+			if err != nil {
+				return err
+			}
+
+			parentHash, err = getEntryHash(parent, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		// None of the entries changed
+		if hash == parentHash {
+			return nil
+		}
+
+		if last.Tree == nil {
+			last.Tree = c
+		}
+
+		entries, err := getTreeEntries(c, path)
+		if err != nil {
+			return err
+		}
+
+		var parentEntries map[string]plumbing.Hash
+		if parent != nil {
+			parentEntries, err = getTreeEntries(parent, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		for name := range current {
+			if _, ok := last.Entries[name]; ok {
+				continue
+			}
+
+			hash, ok := entries[name]
+			if ok && hash != parentEntries[name] {
+				last.Entries[name] = c
+			}
+		}
+
+		if len(last.Entries) == len(current) {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return last, nil
+}
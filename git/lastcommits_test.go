@@ -0,0 +1,84 @@
+package git
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestGetLastCommits(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rev     string
+		path    string
+		tree    string
+		entries map[string]string
+	}{
+		{"master", "", "fcd5474", map[string]string{
+			"README.md": "fcd5474",
+			"src":       "8018d11",
+			"tests":     "3c255e3",
+		}},
+		{"master", "src", "8018d11", map[string]string{
+			"hello.py": "8018d11",
+			"helpers":  "8018d11",
+		}},
+		{"rename", "src", "5f1aa1a", map[string]string{
+			"main.py": "5f1aa1a",
+			"helpers": "8018d11",
+		}},
+	}
+
+	for _, test := range tests {
+		c, err := ResolveRevision(r, test.rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last, err := GetLastCommits(r, c, test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := last.Tree.Hash.String()[:7]
+		if got != test.tree {
+			t.Errorf("wrong last commit for %s:%s: got %v want %v",
+				test.rev, test.path, got, test.tree)
+		}
+
+		if len(last.Entries) != len(test.entries) {
+			t.Errorf("wrong number of entries for %s:%s: got %v want %v",
+				test.rev, test.path, len(last.Entries), len(test.entries))
+		}
+
+		for name, want := range test.entries {
+			commit, ok := last.Entries[name]
+			if !ok {
+				t.Errorf("expected a last commit for %s:%s/%s",
+					test.rev, test.path, name)
+				continue
+			}
+
+			got := commit.Hash.String()[:7]
+			if got != want {
+				t.Errorf("wrong last commit for %s:%s/%s: got %v want %v",
+					test.rev, test.path, name, got, want)
+			}
+		}
+	}
+
+	c, err := ResolveRevision(r, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GetLastCommits(r, c, "nonexistent")
+	if err != object.ErrDirectoryNotFound {
+		t.Errorf("wrong error for a nonexistent tree: got %v want %v",
+			err, object.ErrDirectoryNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+// The description file created by git init, which is not a description
+const defaultDescription = "Unnamed repository; edit this file 'description' to name the repository."
+
+// Metadata is the metadata repository owners can set in the description file
+// and the fudge section of the config file of their repository.
+type Metadata struct {
+	Description   string
+	Owner         string // fudge.owner
+	Homepage      string // fudge.homepage
+	Hidden        bool   // fudge.hidden, hiding the repository from listings
+	DefaultBranch string // fudge.defaultBranch, used instead of HEAD
+	Section       string // fudge.section, grouping repositories on listings
+}
+
+// GetMetadataModTime returns the latest modification time of the description
+// and config files of the repository found at path, and of the directory
+// holding them, so that it also changes when one of them is created or deleted.
+func GetMetadataModTime(path string) (time.Time, error) {
+	path = getGitDir(path)
+
+	var modTime time.Time
+
+	for _, name := range []string{"", "description", "config"} {
+		file, err := os.Stat(filepath.Join(path, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if file.ModTime().After(modTime) {
+			modTime = file.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+// GetRepositoryMetadata reads the metadata of the repository found at path.
+// The description file and the config file are both optional.
+func GetRepositoryMetadata(path string) (*Metadata, error) {
+	path = getGitDir(path)
+	metadata := &Metadata{}
+
+	b, err := ioutil.ReadFile(filepath.Join(path, "description"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	description := strings.TrimSpace(string(b))
+	if description != defaultDescription {
+		metadata.Description = description
+	}
+
+	file, err := os.Open(filepath.Join(path, "config"))
+	if os.IsNotExist(err) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cfg := config.New()
+
+	err = config.NewDecoder(file).Decode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	section := cfg.Section("fudge")
+
+	metadata.Owner = section.Option("owner")
+	metadata.Homepage = section.Option("homepage")
+	metadata.DefaultBranch = section.Option("defaultBranch")
+	metadata.Section = section.Option("section")
+
+	switch strings.ToLower(section.Option("hidden")) {
+	case "true", "yes", "on", "1":
+		metadata.Hidden = true
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,34 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestGetRepositoryMetadata(t *testing.T) {
+	tests := []struct {
+		path string
+		want Metadata
+	}{
+		{"testdata/repository/python", Metadata{}},
+		{"testdata/repositories/team/project.git", Metadata{
+			Description:   "A namespaced project",
+			Owner:         "Jane Doe <jane@example.org>",
+			Homepage:      "https://project.example.org",
+			DefaultBranch: "develop",
+			Section:       "Projects",
+		}},
+		{"testdata/repositories/team/nested/deep", Metadata{Hidden: true}},
+	}
+
+	for _, test := range tests {
+		got, err := GetRepositoryMetadata(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *got != test.want {
+			t.Errorf("wrong metadata for %s: got %+v want %+v",
+				test.path, *got, test.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	fdiff "gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// statGraphWidth is the largest number of +/- characters drawn for a file in
+// diffstats, changes being scaled down beyond it.
+const statGraphWidth = 50
+
+// mboxDate is the fixed date of the mbox "From " lines, as used by Git.
+const mboxDate = "Mon Sep 17 00:00:00 2001"
+
+// plural returns n followed by word, with an "s" unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// scaleChanges returns the number of characters drawn for n changes, the file
+// with the most changes having max of them.
+func scaleChanges(n, max int) int {
+	if max <= statGraphWidth || n == 0 {
+		return n
+	}
+
+	return 1 + n*(statGraphWidth-1)/max
+}
+
+// FormatDiffstat returns the diffstat of diffs, as shown by `git diff --stat`.
+func FormatDiffstat(diffs []*FileDiff) string {
+	nameWidth, maxChanges := 0, 0
+	additions, deletions := 0, 0
+
+	for _, d := range diffs {
+		if len(d.Name()) > nameWidth {
+			nameWidth = len(d.Name())
+		}
+
+		if d.Additions+d.Deletions > maxChanges {
+			maxChanges = d.Additions + d.Deletions
+		}
+
+		additions += d.Additions
+		deletions += d.Deletions
+	}
+
+	countWidth := len(strconv.Itoa(maxChanges))
+
+	b := new(strings.Builder)
+
+	for _, d := range diffs {
+		if d.IsBinary {
+			fmt.Fprintf(b, " %-*s | Bin\n", nameWidth, d.Name())
+			continue
+		}
+
+		graph := strings.Repeat("+", scaleChanges(d.Additions, maxChanges)) +
+			strings.Repeat("-", scaleChanges(d.Deletions, maxChanges))
+
+		line := fmt.Sprintf(" %-*s | %*d %s", nameWidth, d.Name(), countWidth,
+			d.Additions+d.Deletions, graph)
+
+		fmt.Fprintln(b, strings.TrimRight(line, " "))
+	}
+
+	fmt.Fprintf(b, " %s changed", plural(len(diffs), "file"))
+
+	// Like Git, both counts are shown if there are no changes
+	if additions != 0 || deletions == 0 {
+		fmt.Fprintf(b, ", %s(+)", plural(additions, "insertion"))
+	}
+	if deletions != 0 || additions == 0 {
+		fmt.Fprintf(b, ", %s(-)", plural(deletions, "deletion"))
+	}
+
+	fmt.Fprintln(b)
+
+	return b.String()
+}
+
+// splitMessage splits a commit message into its subject, the lines of its
+// first paragraph joined, and its body.
+func splitMessage(message string) (string, string) {
+	message = strings.TrimSpace(message)
+
+	parts := strings.SplitN(message, "\n\n", 2)
+
+	subject := strings.Join(strings.Fields(parts[0]), " ")
+	if len(parts) == 1 {
+		return subject, ""
+	}
+
+	return subject, strings.TrimSpace(parts[1])
+}
+
+// isASCII reports whether s only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WritePatch writes c as an email message, as generated by `git format-patch`.
+// The commit is the number-th patch of a series of total patches, which is
+// not numbered if total is 1.
+func WritePatch(w io.Writer, c *object.Commit, number, total int) error {
+	patch, err := GetCommitPatch(c)
+	if err != nil {
+		return err
+	}
+
+	diffs, err := GetFileDiffs(patch)
+	if err != nil {
+		return err
+	}
+
+	diff := new(bytes.Buffer)
+
+	err = fdiff.NewUnifiedEncoder(diff, fdiff.DefaultContextLines).Encode(patch)
+	if err != nil {
+		return err
+	}
+
+	subject, body := splitMessage(c.Message)
+
+	prefix := "[PATCH]"
+	if total > 1 {
+		prefix = fmt.Sprintf("[PATCH %d/%d]", number, total)
+	}
+
+	b := new(bytes.Buffer)
+
+	fmt.Fprintf(b, "From %s %s\n", c.Hash, mboxDate)
+	fmt.Fprintf(b, "From: %s <%s>\n",
+		mime.QEncoding.Encode("UTF-8", c.Author.Name), c.Author.Email)
+	fmt.Fprintf(b, "Date: %s\n", c.Author.When.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	fmt.Fprintf(b, "Subject: %s\n",
+		mime.QEncoding.Encode("UTF-8", prefix+" "+subject))
+
+	if !isASCII(c.Message) {
+		fmt.Fprint(b, "MIME-Version: 1.0\n")
+		fmt.Fprint(b, "Content-Type: text/plain; charset=UTF-8\n")
+		fmt.Fprint(b, "Content-Transfer-Encoding: 8bit\n")
+	}
+
+	fmt.Fprintln(b)
+
+	if body != "" {
+		fmt.Fprintf(b, "%s\n", body)
+	}
+
+	fmt.Fprintf(b, "---\n%s\n%s-- \nfudge\n\n", FormatDiffstat(diffs), diff)
+
+	_, err = b.WriteTo(w)
+
+	return err
+}
+
+// WriteMbox writes commits as a series of patches in the mbox format, oldest
+// first. Merge commits are skipped, like `git format-patch` does.
+func WriteMbox(w io.Writer, commits []*object.Commit) error {
+	var series []*object.Commit
+
+	for i := len(commits) - 1; i >= 0; i-- {
+		if commits[i].NumParents() <= 1 {
+			series = append(series, commits[i])
+		}
+	}
+
+	for i, c := range series {
+		err := WritePatch(w, c, i+1, len(series))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatDiffstat(t *testing.T) {
+	tests := []struct {
+		diffs []*FileDiff
+		want  string
+	}{
+		{[]*FileDiff{
+			{From: "README.md", To: "README.md", Additions: 1, Deletions: 1},
+			{To: "tests/test_add.py", Additions: 12},
+			{From: "logo.png", IsBinary: true},
+		}, "" +
+			" README.md         |  2 +-\n" +
+			" tests/test_add.py | 12 ++++++++++++\n" +
+			" logo.png          | Bin\n" +
+			" 3 files changed, 13 insertions(+), 1 deletion(-)\n"},
+		{[]*FileDiff{
+			{From: "main.go", To: "main.go", Deletions: 100},
+		}, "" +
+			" main.go | 100 " + strings.Repeat("-", statGraphWidth) + "\n" +
+			" 1 file changed, 100 deletions(-)\n"},
+		{[]*FileDiff{
+			{To: "empty"},
+		}, "" +
+			" empty | 0\n" +
+			" 1 file changed, 0 insertions(+), 0 deletions(-)\n"},
+	}
+
+	for _, test := range tests {
+		got := FormatDiffstat(test.diffs)
+		if got != test.want {
+			t.Errorf("wrong diffstat: got\n%s\nwant\n%s", got, test.want)
+		}
+	}
+}
+
+func TestWritePatch(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ResolveRevision(r, "fcd547424101b07adbd1e6cf4a06305342ae8f66")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := new(bytes.Buffer)
+
+	err = WritePatch(b, c, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"From fcd547424101b07adbd1e6cf4a06305342ae8f66 Mon Sep 17 00:00:00 2001\n",
+		"\nSubject: [PATCH 2/3] Edit README.md\n",
+		"\n---\n README.md | 2 +-\n 1 file changed, 1 insertion(+), 1 deletion(-)\n\n",
+		"\ndiff --git a/README.md b/README.md\n",
+	}
+
+	for _, s := range want {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("expected the patch to contain %q, got %q", s, b)
+		}
+	}
+
+	if !strings.HasPrefix(b.String(), want[0]) {
+		t.Errorf("wrong patch start: got %q", b)
+	}
+}
+
+func TestWriteMbox(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmp := compareRevisions(t, r, "v0.1.0", "rename", 0)
+
+	b := new(bytes.Buffer)
+
+	err = WriteMbox(b, cmp.Commits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subjects := []string{
+		"Subject: [PATCH 1/3] Add tests\n",
+		"Subject: [PATCH 2/3] Edit README.md\n",
+		"Subject: [PATCH 3/3] Rename hello.py to main.py\n",
+	}
+
+	last := -1
+	for _, subject := range subjects {
+		i := strings.Index(b.String(), subject)
+		if i <= last {
+			t.Errorf("expected %q to follow the previous patch", subject)
+		}
+
+		last = i
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		message, subject, body string
+	}{
+		{"Fix the build\n", "Fix the build", ""},
+		{"Fix the build\non OpenBSD\n\nThe linker flags\ndiffer.\n",
+			"Fix the build on OpenBSD", "The linker flags\ndiffer."},
+	}
+
+	for _, test := range tests {
+		subject, body := splitMessage(test.message)
+		if subject != test.subject || body != test.body {
+			t.Errorf("wrong split of %q: got (%q, %q) want (%q, %q)",
+				test.message, subject, body, test.subject, test.body)
+		}
+	}
+}
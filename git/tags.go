@@ -0,0 +1,84 @@
+package git
+
+import (
+	"sort"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type Tag struct {
+	Name       string
+	Commit     *object.Commit // The tagged commit
+	Annotation *object.Tag    // The tag object, nil for lightweight tags
+}
+
+// Date returns the date the tag was created on for annotated tags, and the
+// date the tagged commit was committed on for lightweight tags.
+func (t *Tag) Date() time.Time {
+	if t.Annotation != nil {
+		return t.Annotation.Tagger.When
+	}
+
+	return t.Commit.Committer.When
+}
+
+// GetRepositoryTags returns the tags of the repository pointing to commits,
+// the most recent first.
+func GetRepositoryTags(r *git.Repository) ([]*Tag, error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []*Tag
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tag := &Tag{
+			Name: ref.Name().Short(),
+		}
+
+		annotation, err := r.TagObject(ref.Hash())
+		switch err {
+		case nil:
+			commit, err := annotation.Commit()
+			if err == object.ErrUnsupportedObject {
+				// Tags pointing to trees or blobs are left out
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			tag.Annotation = annotation
+			tag.Commit = commit
+		case plumbing.ErrObjectNotFound:
+			commit, err := r.CommitObject(ref.Hash())
+			if err == plumbing.ErrObjectNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			tag.Commit = commit
+		default:
+			return err
+		}
+
+		tags = append(tags, tag)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Date().After(tags[j].Date())
+	})
+
+	return tags, nil
+}
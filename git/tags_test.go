@@ -0,0 +1,46 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestGetRepositoryTags(t *testing.T) {
+	r, err := OpenRepository("testdata/repository", "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetRepositoryTags(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name        string
+		commit      string
+		isAnnotated bool
+	}{
+		{"v0.2.0", "3c255e3f5a626bc816102e91e2d8f8f94f733ed5", true},
+		{"v0.1.0", "8018d114b13d3b65862d450cf77189344ac094c1", false},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of tags: got %d want %d", len(got), len(want))
+	}
+
+	for i, tag := range got {
+		if tag.Name != want[i].name {
+			t.Errorf("wrong tag name: got %s want %s", tag.Name, want[i].name)
+		}
+
+		if tag.Commit.Hash.String() != want[i].commit {
+			t.Errorf("wrong commit for tag %s: got %s want %s",
+				tag.Name, tag.Commit.Hash, want[i].commit)
+		}
+
+		if (tag.Annotation != nil) != want[i].isAnnotated {
+			t.Errorf("wrong annotation for tag %s: got %v want %v",
+				tag.Name, tag.Annotation != nil, want[i].isAnnotated)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package git
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version parsed from a tag name.
+type Version struct {
+	Numbers    [3]int   // The major, minor and patch numbers
+	Prerelease []string // The dot-separated pre-release identifiers
+}
+
+// ParseVersion parses name as a semantic version, optionally prefixed by "v".
+// The minor and patch numbers can be left out, e.g. "v1.2". Build metadata
+// is ignored. ParseVersion reports whether name is a version.
+func ParseVersion(name string) (*Version, bool) {
+	name = strings.TrimPrefix(name, "v")
+
+	if i := strings.Index(name, "+"); i != -1 {
+		name = name[:i]
+	}
+
+	v := &Version{}
+
+	if i := strings.Index(name, "-"); i != -1 {
+		v.Prerelease = strings.Split(name[i+1:], ".")
+		name = name[:i]
+
+		for _, id := range v.Prerelease {
+			if id == "" {
+				return nil, false
+			}
+		}
+	}
+
+	parts := strings.Split(name, ".")
+	if len(parts) > len(v.Numbers) {
+		return nil, false
+	}
+
+	for i, part := range parts {
+		// Signs are rejected as well as empty parts
+		if part == "" || part[0] < '0' || part[0] > '9' {
+			return nil, false
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+
+		v.Numbers[i] = n
+	}
+
+	return v, true
+}
+
+// IsPrerelease reports whether v is a pre-release, e.g. "1.0.0-rc.1".
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) != 0
+}
+
+// compareInts returns -1, 0 or 1 depending on whether a is lower than, equal
+// to or greater than b.
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// comparePrerelease compares two pre-release identifiers: numeric ones are
+// compared numerically and have a lower precedence than alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	m, errA := strconv.Atoi(a)
+	n, errB := strconv.Atoi(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(m, n)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// Compare returns -1, 0 or 1 depending on whether v has a lower, the same or
+// a higher precedence than other.
+func (v *Version) Compare(other *Version) int {
+	for i := range v.Numbers {
+		if c := compareInts(v.Numbers[i], other.Numbers[i]); c != 0 {
+			return c
+		}
+	}
+
+	// Pre-releases precede the release
+	switch {
+	case !v.IsPrerelease() && !other.IsPrerelease():
+		return 0
+	case !v.IsPrerelease():
+		return 1
+	case !other.IsPrerelease():
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		c := comparePrerelease(v.Prerelease[i], other.Prerelease[i])
+		if c != 0 {
+			return c
+		}
+	}
+
+	return compareInts(len(v.Prerelease), len(other.Prerelease))
+}
+
+// SortTagsByVersion sorts tags by version, the highest first. Tags which are
+// not versions come last, keeping their order.
+func SortTagsByVersion(tags []*Tag) {
+	versions := make(map[*Tag]*Version)
+	for _, tag := range tags {
+		if v, ok := ParseVersion(tag.Name); ok {
+			versions[tag] = v
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, b := versions[tags[i]], versions[tags[j]]
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+
+		return a.Compare(b) > 0
+	})
+}
+
+// GetLatestRelease returns the tag of the highest version which is not a
+// pre-release, or the most recent tag if there is none. It returns nil if
+// there are no tags.
+func GetLatestRelease(tags []*Tag) *Tag {
+	var latest *Tag
+	var latestVersion *Version
+
+	for _, tag := range tags {
+		v, ok := ParseVersion(tag.Name)
+		if !ok || v.IsPrerelease() {
+			continue
+		}
+
+		if latestVersion == nil || v.Compare(latestVersion) > 0 {
+			latest, latestVersion = tag, v
+		}
+	}
+
+	if latest != nil {
+		return latest
+	}
+
+	for _, tag := range tags {
+		if latest == nil || tag.Date().After(latest.Date()) {
+			latest = tag
+		}
+	}
+
+	return latest
+}
@@ -0,0 +1,109 @@
+package git
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+		want  Version
+	}{
+		{"v1.2.3", true, Version{Numbers: [3]int{1, 2, 3}}},
+		{"1.2", true, Version{Numbers: [3]int{1, 2, 0}}},
+		{"v2", true, Version{Numbers: [3]int{2, 0, 0}}},
+		{"v1.0.0-rc.1+build.5", true,
+			Version{Numbers: [3]int{1, 0, 0}, Prerelease: []string{"rc", "1"}}},
+		{"release", false, Version{}},
+		{"v1.2.3.4", false, Version{}},
+		{"v1..2", false, Version{}},
+		{"v1.-2", false, Version{}},
+		{"v1.0.0-", false, Version{}},
+		{"v1.0.0-rc..1", false, Version{}},
+	}
+
+	for _, test := range tests {
+		v, ok := ParseVersion(test.name)
+		if ok != test.valid {
+			t.Errorf("wrong validity for %q: got %v want %v", test.name, ok, test.valid)
+			continue
+		}
+
+		if ok && v.Compare(&test.want) != 0 {
+			t.Errorf("wrong version for %q: got %+v want %+v", test.name, v, test.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	// Each version has a lower precedence than the next one
+	names := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"v1.0.1",
+		"v1.2",
+		"v1.10.0",
+		"2.0.0",
+	}
+
+	for i := 0; i < len(names)-1; i++ {
+		a, _ := ParseVersion(names[i])
+		b, _ := ParseVersion(names[i+1])
+
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("expected %s to precede %s", names[i], names[i+1])
+		}
+	}
+}
+
+func TestSortTagsByVersion(t *testing.T) {
+	newTag := func(name string, days int) *Tag {
+		when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days)
+
+		return &Tag{
+			Name:   name,
+			Commit: &object.Commit{Committer: object.Signature{When: when}},
+		}
+	}
+
+	tags := []*Tag{
+		newTag("nightly", 5),
+		newTag("v1.10.0-rc.1", 4),
+		newTag("v1.9.0", 3),
+		newTag("latest", 2),
+		newTag("v1.10.0", 1),
+	}
+
+	latest := GetLatestRelease(tags)
+	if latest == nil || latest.Name != "v1.10.0" {
+		t.Errorf("wrong latest release: got %v want v1.10.0", latest)
+	}
+
+	SortTagsByVersion(tags)
+
+	want := []string{"v1.10.0", "v1.10.0-rc.1", "v1.9.0", "nightly", "latest"}
+	for i, tag := range tags {
+		if tag.Name != want[i] {
+			t.Errorf("wrong tag at %d: got %s want %s", i, tag.Name, want[i])
+		}
+	}
+
+	latest = GetLatestRelease([]*Tag{newTag("nightly", 1), newTag("latest", 2)})
+	if latest == nil || latest.Name != "latest" {
+		t.Errorf("wrong latest tag: got %v want latest", latest)
+	}
+
+	if GetLatestRelease(nil) != nil {
+		t.Error("expected no latest release without tags")
+	}
+}
@@ -1,12 +1,16 @@
 module bovarys.me/fudge
 
-go 1.13
+go 1.16
 
 require (
 	github.com/alecthomas/chroma v0.6.7
 	github.com/dustin/go-humanize v1.0.0
 	github.com/gorilla/handlers v1.4.1
 	github.com/gorilla/mux v1.7.3
+	github.com/prometheus/client_golang v1.12.2
+	github.com/russross/blackfriday/v2 v2.1.0
+	github.com/sergi/go-diff v1.0.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	gopkg.in/src-d/go-git.v4 v4.13.1
-	gopkg.in/yaml.v2 v2.2.4
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,532 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bovarys.me/fudge/git"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// The path prefix of the current version of the JSON API
+const apiPrefix = "/api/v1"
+
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type apiRepository struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Owner       string `json:"owner,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+	Section     string `json:"section,omitempty"`
+	URL         string `json:"url"`
+	CloneURL    string `json:"clone_url,omitempty"`
+}
+
+type apiRef struct {
+	Name  string `json:"name"`
+	IsTag bool   `json:"is_tag"`
+}
+
+type apiRepositoryDetails struct {
+	*apiRepository
+	DefaultBranch string    `json:"default_branch"`
+	Refs          []*apiRef `json:"refs"`
+}
+
+type apiSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type apiCommit struct {
+	Hash      string        `json:"hash"`
+	Subject   string        `json:"subject"`
+	Message   string        `json:"message"`
+	Author    *apiSignature `json:"author"`
+	Committer *apiSignature `json:"committer"`
+	Parents   []string      `json:"parents"`
+	URL       string        `json:"url"`
+}
+
+type apiFileDiff struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	IsBinary  bool   `json:"is_binary"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Patch     string `json:"patch"`
+}
+
+type apiCommitDetails struct {
+	*apiCommit
+	Files []*apiFileDiff `json:"files"`
+}
+
+type apiCommitPage struct {
+	Commits []*apiCommit `json:"commits"`
+	Prev    string       `json:"prev,omitempty"` // The cursor of the previous page
+	Next    string       `json:"next,omitempty"` // The cursor of the next page
+}
+
+type apiTreeObject struct {
+	Name       string     `json:"name"`
+	Path       string     `json:"path"`
+	IsFile     bool       `json:"is_file"`
+	Size       string     `json:"size,omitempty"` // The object humanized size
+	URL        string     `json:"url"`
+	LastCommit *apiCommit `json:"last_commit,omitempty"`
+}
+
+type apiTree struct {
+	Rev     string           `json:"rev"`
+	Path    string           `json:"path"`
+	Commit  *apiCommit       `json:"commit"`
+	Objects []*apiTreeObject `json:"objects"`
+}
+
+type apiBlob struct {
+	Rev      string     `json:"rev"`
+	Path     string     `json:"path"`
+	Name     string     `json:"name"`
+	IsBinary bool       `json:"is_binary"`
+	Size     string     `json:"size"` // The blob humanized size
+	Commit   *apiCommit `json:"commit"`
+	RawURL   string     `json:"raw_url"`
+}
+
+type apiBlameLine struct {
+	Number int       `json:"number"`
+	Hash   string    `json:"hash"`
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+}
+
+type apiBlame struct {
+	Rev    string          `json:"rev"`
+	Path   string          `json:"path"`
+	Commit *apiCommit      `json:"commit"`
+	Lines  []*apiBlameLine `json:"lines"`
+}
+
+func (h *Handler) setAPIRoutes(router *mux.Router) {
+	api := router.PathPrefix(apiPrefix).Subrouter()
+
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.showError(w, r, http.StatusNotFound, nil)
+	})
+
+	api.HandleFunc("/repos", h.sendAPIRepositories)
+	api.PathPrefix("/repos/").HandlerFunc(h.serveAPIRepository)
+
+	h.apiRepositoryRouter = mux.NewRouter()
+	h.setAPIRepositoryRoutes(h.apiRepositoryRouter)
+}
+
+// setAPIRepositoryRoutes sets the API routes of repositories, relative to the
+// repository path.
+func (h *Handler) setAPIRepositoryRoutes(router *mux.Router) {
+	router.HandleFunc("/", h.sendAPIRepository)
+	router.HandleFunc("/commits", h.sendAPICommits)
+	router.HandleFunc("/commits/{spec:.*}", h.sendAPICommits)
+	router.HandleFunc("/commit/{hash}", h.sendAPICommit)
+	router.HandleFunc("/log/{spec:.*}", h.sendAPICommits)
+	router.HandleFunc("/tree", h.sendAPITree)
+	router.HandleFunc("/tree/{spec:.*}", h.sendAPITree)
+	router.HandleFunc("/blob/{spec:.*}", h.sendAPIBlob)
+	router.HandleFunc("/blame/{spec:.*}", h.sendAPIBlame)
+}
+
+// isAPIRequest reports whether r was made to the JSON API, whose errors are
+// sent as JSON objects instead of HTML pages.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+func (h *Handler) sendJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func (h *Handler) sendAPIError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	message := http.StatusText(status)
+	if err != nil && h.config(r).Debug {
+		message = err.Error()
+	}
+
+	h.sendJSON(w, status, map[string]*apiError{
+		"error": {
+			Status:  status,
+			Message: message,
+		},
+	})
+}
+
+func (h *Handler) newAPIRepository(r *http.Request, repository *listedRepository) *apiRepository {
+	return &apiRepository{
+		Name:        repository.Name,
+		Description: repository.Description,
+		Owner:       repository.Owner,
+		Homepage:    repository.Homepage,
+		Section:     repository.Section,
+		URL:         fmt.Sprintf("%s/%s/", h.getBaseURL(r), repository.Name),
+		CloneURL:    h.getCloneURL(r, repository.Name),
+	}
+}
+
+func newAPISignature(s object.Signature) *apiSignature {
+	return &apiSignature{
+		Name:  s.Name,
+		Email: s.Email,
+		Date:  s.When,
+	}
+}
+
+func (h *Handler) newAPICommit(r *http.Request, c *object.Commit) *apiCommit {
+	commit := &apiCommit{
+		Hash:      c.Hash.String(),
+		Subject:   strings.SplitN(c.Message, "\n", 2)[0],
+		Message:   c.Message,
+		Author:    newAPISignature(c.Author),
+		Committer: newAPISignature(c.Committer),
+		Parents:   []string{},
+		URL: fmt.Sprintf("%s/%s/commit/%s", h.getBaseURL(r),
+			mux.Vars(r)["repository"], c.Hash),
+	}
+
+	for _, parent := range c.ParentHashes {
+		commit.Parents = append(commit.Parents, parent.String())
+	}
+
+	return commit
+}
+
+// joinPath joins the elements of a path within a repository, leaving out the
+// empty ones.
+func joinPath(elems ...string) string {
+	var parts []string
+	for _, elem := range elems {
+		if elem != "" {
+			parts = append(parts, strings.Trim(elem, "/"))
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func (h *Handler) sendAPIRepositories(w http.ResponseWriter, r *http.Request) {
+	listed, err := h.getListedRepositories(r)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	repositories := []*apiRepository{}
+	for _, repository := range listed {
+		repositories = append(repositories, h.newAPIRepository(r, repository))
+	}
+
+	h.sendJSON(w, http.StatusOK, repositories)
+}
+
+func (h *Handler) sendAPIRepository(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	name := mux.Vars(r)["repository"]
+
+	metadata, err := h.getMetadata(r, name)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	rev, err := getDefaultRevision(metadata, repository)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	refs, err := git.GetRepositoryRefs(repository)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := &apiRepositoryDetails{
+		apiRepository: h.newAPIRepository(r, &listedRepository{name, metadata}),
+		DefaultBranch: rev,
+		Refs:          []*apiRef{},
+	}
+
+	for _, ref := range refs {
+		details.Refs = append(details.Refs, &apiRef{
+			Name:  ref.Name,
+			IsTag: ref.IsTag,
+		})
+	}
+
+	h.sendJSON(w, http.StatusOK, details)
+}
+
+func (h *Handler) sendAPICommits(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
+	opts := getCommitsOptions(r)
+	opts.Path = mux.Vars(r)["path"]
+
+	page, err := git.GetRepositoryCommits(repository, commit, opts)
+	if err == plumbing.ErrObjectNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := &apiCommitPage{
+		Commits: []*apiCommit{},
+		Prev:    page.Prev,
+		Next:    page.Next,
+	}
+
+	for _, c := range page.Commits {
+		result.Commits = append(result.Commits, h.newAPICommit(r, c))
+	}
+
+	h.sendJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) sendAPICommit(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	commit, err := git.ResolveRevision(repository, mux.Vars(r)["hash"])
+	if err == plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	patch, err := git.GetCommitPatch(commit)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	diffs, err := git.GetFileDiffs(patch)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := &apiCommitDetails{
+		apiCommit: h.newAPICommit(r, commit),
+		Files:     []*apiFileDiff{},
+	}
+
+	for _, diff := range diffs {
+		details.Files = append(details.Files, &apiFileDiff{
+			From:      diff.From,
+			To:        diff.To,
+			IsBinary:  diff.IsBinary,
+			Additions: diff.Additions,
+			Deletions: diff.Deletions,
+			Patch:     diff.Patch,
+		})
+	}
+
+	h.sendJSON(w, http.StatusOK, details)
+}
+
+func (h *Handler) sendAPITree(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	tree, err := git.GetRepositoryTree(commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	objects, err := h.getTreeObjects(r, tree)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	last, err := h.getLastCommits(r, repository, commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := &apiTree{
+		Rev:     vars["rev"],
+		Path:    vars["path"],
+		Commit:  h.newAPICommit(r, last.Tree),
+		Objects: []*apiTreeObject{},
+	}
+
+	for _, o := range objects {
+		view := "tree"
+		if o.IsFile {
+			view = "blob"
+		}
+
+		path := joinPath(vars["path"], o.Name)
+
+		object := &apiTreeObject{
+			Name:   o.Name,
+			Path:   path,
+			IsFile: o.IsFile,
+			URL: fmt.Sprintf("%s%s/repos/%s/%s/%s", h.getBaseURL(r),
+				apiPrefix, vars["repository"], view, joinPath(vars["rev"], path)),
+		}
+
+		// Like on tree pages, only the size of files is shown
+		if o.IsFile {
+			object.Size = o.Size
+		}
+
+		if c, ok := last.Entries[o.Name]; ok {
+			object.LastCommit = h.newAPICommit(r, c)
+		}
+
+		result.Objects = append(result.Objects, object)
+	}
+
+	h.sendJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) sendAPIBlob(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	blob, err := git.GetRepositoryBlob(commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	blob.Reader.Close()
+
+	last, err := h.getLastCommit(r, repository, commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := &apiBlob{
+		Rev:      vars["rev"],
+		Path:     vars["path"],
+		Name:     blob.Name,
+		IsBinary: blob.IsBinary,
+		Size:     blob.Size,
+		Commit:   h.newAPICommit(r, last),
+		RawURL: fmt.Sprintf("%s/%s/raw/%s", h.getBaseURL(r), vars["repository"],
+			joinPath(vars["rev"], vars["path"])),
+	}
+
+	h.sendJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) sendAPIBlame(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	blob, err := git.GetRepositoryBlob(commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	blob.Reader.Close()
+
+	last, err := h.getLastCommit(r, repository, commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := &apiBlame{
+		Rev:    vars["rev"],
+		Path:   vars["path"],
+		Commit: h.newAPICommit(r, last),
+		Lines:  []*apiBlameLine{},
+	}
+
+	if !blob.IsBinary {
+		blame, err := git.GetBlame(commit, vars["path"])
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		for i, line := range blame {
+			result.Lines = append(result.Lines, &apiBlameLine{
+				Number: i + 1,
+				Hash:   line.Hash,
+				Author: line.Author,
+				Date:   line.Date,
+			})
+		}
+	}
+
+	h.sendJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestAPI(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/api/v1/repos", http.StatusOK},
+		{"/api/v1/repos/python", http.StatusOK},
+		{"/api/v1/repos/nonexistent", http.StatusNotFound},
+		{"/api/v1/repos/python/tree", http.StatusOK},
+		{"/api/v1/repos/python/tree/release/0.1/tests", http.StatusOK},
+		{"/api/v1/repos/python/tree/nonexistent", http.StatusNotFound},
+		{"/api/v1/repos/python/blob/master/src/hello.py", http.StatusOK},
+		{"/api/v1/repos/python/blob/master/nonexistent", http.StatusNotFound},
+		{"/api/v1/repos/python/blame/master/README.md", http.StatusOK},
+		{"/api/v1/repos/python/commits/release/0.1", http.StatusOK},
+		{"/api/v1/repos/python/commits?after=0123", http.StatusNotFound},
+		{"/api/v1/repos/python/log/rename/src/main.py", http.StatusOK},
+		{"/api/v1/repos/python/commit/8018d114b13d3b65862d450cf77189344ac094c1", http.StatusOK},
+		{"/api/v1/repos/python/commit/nonexistent", http.StatusNotFound},
+		{"/api/v1/nonexistent", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		status := recorder.Code
+		if status != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, status, test.status)
+		}
+
+		contentType := recorder.Header().Get("Content-Type")
+		if contentType != "application/json; charset=utf-8" {
+			t.Errorf("wrong content type for %s: got %q", test.url, contentType)
+		}
+
+		if status == http.StatusOK {
+			continue
+		}
+
+		var body map[string]*apiError
+		err = json.Unmarshal(recorder.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatalf("invalid error object for %s: %v", test.url, err)
+		}
+
+		if body["error"] == nil || body["error"].Status != status {
+			t.Errorf("wrong error object for %s: %s", test.url, recorder.Body)
+		}
+	}
+}
+
+func TestAPICommits(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := http.NewRequest("GET", "/api/v1/repos/python/commits?n=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, request)
+
+	var page apiCommitPage
+	err = json.Unmarshal(recorder.Body.Bytes(), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subjects := []string{"Edit README.md", "Add tests"}
+
+	if len(page.Commits) != len(subjects) {
+		t.Fatalf("wrong number of commits: got %d want %d",
+			len(page.Commits), len(subjects))
+	}
+
+	for i, commit := range page.Commits {
+		if commit.Subject != subjects[i] {
+			t.Errorf("wrong commit subject: got %q want %q",
+				commit.Subject, subjects[i])
+		}
+	}
+
+	want := "3c255e3f5a626bc816102e91e2d8f8f94f733ed5"
+	if page.Next != want {
+		t.Errorf("wrong next cursor: got %q want %q", page.Next, want)
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type userKey struct{}
+
+// getUser returns the name of the user authenticated by r, or an empty string
+// for anonymous requests.
+func getUser(r *http.Request) string {
+	user, _ := r.Context().Value(userKey{}).(string)
+	return user
+}
+
+// canRead reports whether the user of r can read the repository. As names
+// are looked up with and without a ".git" suffix, both must share the same
+// permissions.
+func (h *Handler) canRead(r *http.Request, repository string) bool {
+	name := strings.TrimSuffix(repository, ".git")
+	return h.state(r).auth.CanRead(getUser(r), name)
+}
+
+// authenticate is a middleware checking the HTTP Basic credentials of
+// requests. Requests with invalid credentials are rejected, whichever
+// repository they target, so that they don't reveal private repositories.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !h.state(r).auth.Authenticate(user, password) {
+			h.requireAuthentication(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userKey{}, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (h *Handler) requireAuthentication(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate",
+		fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, h.state(r).auth.Realm))
+
+	if isAPIRequest(r) {
+		h.sendAPIError(w, r, http.StatusUnauthorized, errors.New("invalid credentials"))
+		return
+	}
+
+	http.Error(w, "Authentication required", http.StatusUnauthorized)
+}
+
+// login asks browsers for credentials, which they then send along with the
+// next requests, and redirects them to the home page once authenticated.
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	if !h.state(r).auth.Enabled() {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	if getUser(r) == "" {
+		h.requireAuthentication(w, r)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
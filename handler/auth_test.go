@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestPrivateRepositories(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Auth: config.AuthConfig{
+			// The passwords are "alicepw" and "bobpw"
+			Users: map[string]string{
+				"alice": "$2a$04$kAt2RBXvaTt7zRloxS80F.JN4AsIkLqzBSLas1p0lhJP1njlSsd46",
+				"bob":   "$2y$04$iu.sz4gqKY5B4ESQJPTWAusE.OEIuci4Yy.57pnHsEQG3d5WHtYZ6",
+			},
+			Private: map[string][]string{
+				"python": {"alice"},
+			},
+		},
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url, user, password string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user != "" {
+			request.SetBasicAuth(user, password)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	urls := []string{
+		"/python/",
+		"/python/tree/master/src",
+		"/python/blob/master/README.md",
+		"/python/raw/master/README.md",
+		"/python/commits",
+		"/python/commit/8018d114b13d3b65862d450cf77189344ac094c1",
+		"/python/archive/master.zip",
+		"/python/commits.atom",
+		"/api/v1/repos/python",
+		"/api/v1/repos/python/tree/master",
+	}
+
+	for _, url := range urls {
+		tests := []struct {
+			user     string
+			password string
+			status   int
+		}{
+			{"", "", http.StatusNotFound},
+			{"bob", "bobpw", http.StatusNotFound},
+			{"alice", "alicepw", http.StatusOK},
+			{"alice", "bobpw", http.StatusUnauthorized},
+		}
+
+		for _, test := range tests {
+			status := get(url, test.user, test.password).Code
+			if status != test.status {
+				t.Errorf("wrong status code for %s as %q: got %v want %v",
+					url, test.user, status, test.status)
+			}
+		}
+	}
+
+	for _, url := range []string{"/", "/api/v1/repos", "/activity.atom"} {
+		if strings.Contains(get(url, "", "").Body.String(), "python") {
+			t.Errorf("%s lists a private repository", url)
+		}
+
+		if !strings.Contains(get(url, "alice", "alicepw").Body.String(), "python") {
+			t.Errorf("%s does not list a readable private repository", url)
+		}
+	}
+
+	// Private repositories are not redirected to their trailing slash either
+	missing := get("/nonexistent", "", "")
+	for _, url := range []string{"/python", "/python.git"} {
+		recorder := get(url, "", "")
+		if recorder.Code != missing.Code || recorder.Body.String() != missing.Body.String() {
+			t.Errorf("%s is not answered as a missing repository: got %v want %v",
+				url, recorder.Code, missing.Code)
+		}
+
+		if status := get(url, "alice", "alicepw").Code; status != http.StatusMovedPermanently {
+			t.Errorf("wrong status code for %s as %q: got %v want %v",
+				url, "alice", status, http.StatusMovedPermanently)
+		}
+	}
+
+	// Git only sends credentials once challenged
+	for _, url := range []string{
+		"/python/info/refs?service=git-upload-pack",
+		"/nonexistent/info/refs?service=git-upload-pack",
+	} {
+		if status := get(url, "", "").Code; status != http.StatusUnauthorized {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				url, status, http.StatusUnauthorized)
+		}
+
+		if status := get(url, "bob", "bobpw").Code; status != http.StatusNotFound {
+			t.Errorf("wrong status code for %s as bob: got %v want %v",
+				url, status, http.StatusNotFound)
+		}
+	}
+
+	if status := get("/python/info/refs?service=git-upload-pack",
+		"alice", "alicepw").Code; status != http.StatusOK {
+		t.Errorf("wrong status code for clones as alice: got %v", status)
+	}
+
+	recorder := get("/login", "", "")
+	if recorder.Code != http.StatusUnauthorized ||
+		recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Error("expected /login to ask for credentials")
+	}
+
+	if get("/login", "bob", "bobpw").Code != http.StatusFound {
+		t.Error("expected /login to redirect authenticated users")
+	}
+}
@@ -0,0 +1,423 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bovarys.me/fudge/cache"
+	"bovarys.me/fudge/config"
+	"bovarys.me/fudge/git"
+	"bovarys.me/fudge/util"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// caches holds the values kept in memory across requests.
+type caches struct {
+	repositories *cache.LRU // Pools of opened repositories, keyed by path
+	trees        *cache.LRU // Tree listings, keyed by tree hash
+	blobs        *cache.LRU // Highlighted blobs, keyed by blob hash and name
+	lastCommits  *cache.LRU // Last commits of tree entries, keyed by commit and path
+	comparisons  *cache.LRU // Comparisons, keyed by the compared commits and limit
+
+	mu           sync.Mutex
+	names        []string // The repository names found in the repo root
+	namesModTime time.Time
+	metadata     map[string]*cachedMetadata // Repository metadata, keyed by path
+}
+
+// cachedMetadata is the metadata read from a repository, before the options of
+// the config are applied.
+type cachedMetadata struct {
+	metadata *git.Metadata
+	modTime  time.Time // The modification time of the files it was read from
+}
+
+func newCaches(cfg *config.CacheConfig) *caches {
+	return &caches{
+		repositories: cache.NewLRU(cfg.Repositories),
+		trees:        cache.NewLRU(cfg.Trees),
+		blobs:        cache.NewLRU(cfg.Blobs),
+		lastCommits:  cache.NewLRU(cfg.LastCommits),
+		comparisons:  cache.NewLRU(cfg.Comparisons),
+		metadata:     make(map[string]*cachedMetadata),
+	}
+}
+
+// repositoryPool holds the idle repositories opened from a given path. As
+// go-git repositories are not safe for concurrent use, each repository is
+// used by a single request at a time.
+type repositoryPool struct {
+	mu      sync.Mutex
+	modTime time.Time // The refs modification time of the idle repositories
+	idle    []*gogit.Repository
+}
+
+// get returns an idle repository if none of the refs changed since it was
+// opened, or nil otherwise.
+func (p *repositoryPool) get(modTime time.Time) *gogit.Repository {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.modTime.Equal(modTime) {
+		p.modTime = modTime
+		p.idle = nil
+	}
+
+	if len(p.idle) == 0 {
+		return nil
+	}
+
+	repository := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+
+	return repository
+}
+
+// put makes a repository opened at modTime available to other requests, unless
+// enough idle copies of it are already kept.
+func (p *repositoryPool) put(repository *gogit.Repository, modTime time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.modTime.Equal(modTime) && len(p.idle) < maxIdleRepositories {
+		p.idle = append(p.idle, repository)
+	}
+}
+
+type lease struct {
+	pool       *repositoryPool
+	repository *gogit.Repository
+	modTime    time.Time
+}
+
+type leasesKey struct{}
+
+// releaseRepositories is a middleware returning the repositories used by a
+// request to their pool once it has been served.
+func (h *Handler) releaseRepositories(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var leases []*lease
+
+		ctx := context.WithValue(r.Context(), leasesKey{}, &leases)
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		for _, l := range leases {
+			l.pool.put(l.repository, l.modTime)
+		}
+	})
+}
+
+// getRepository opens the repository with the given name, or reuses one opened
+// by a previous request if none of its refs changed since. The repository is
+// returned to the cache once r has been served. Repositories the user of r
+// cannot read are reported as not existing.
+func (h *Handler) getRepository(r *http.Request, name string) (*gogit.Repository, error) {
+	s := h.state(r)
+
+	if !h.canRead(r, name) {
+		return nil, gogit.ErrRepositoryNotExists
+	}
+
+	path, err := git.FindRepository(s.config.RepoRoot, name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	modTime, err := git.GetRefsModTime(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool *repositoryPool
+	if value, ok := s.caches.repositories.Get(path); ok {
+		pool = value.(*repositoryPool)
+	} else {
+		pool = &repositoryPool{}
+		s.caches.repositories.Add(path, pool, 1)
+	}
+
+	repository := pool.get(modTime)
+	h.metrics.lookup("repositories", repository != nil)
+	if repository == nil {
+		h.metrics.repositoryOpens.Inc()
+
+		repository, err = gogit.PlainOpen(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if leases, ok := r.Context().Value(leasesKey{}).(*[]*lease); ok {
+		*leases = append(*leases, &lease{pool, repository, modTime})
+	}
+
+	return repository, nil
+}
+
+// getRepositoryMetadata returns a copy of the metadata of the repository found
+// at path, which is only read again once its description or config file is
+// modified.
+func (h *Handler) getRepositoryMetadata(r *http.Request, path string) (*git.Metadata, error) {
+	s := h.state(r)
+
+	modTime, err := git.GetMetadataModTime(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.caches.mu.Lock()
+	cached, ok := s.caches.metadata[path]
+	s.caches.mu.Unlock()
+
+	h.metrics.lookup("metadata", ok && cached.modTime.Equal(modTime))
+	if !ok || !cached.modTime.Equal(modTime) {
+		metadata, err := git.GetRepositoryMetadata(path)
+		if err != nil {
+			return nil, err
+		}
+
+		cached = &cachedMetadata{metadata, modTime}
+
+		s.caches.mu.Lock()
+		s.caches.metadata[path] = cached
+		s.caches.mu.Unlock()
+	}
+
+	metadata := *cached.metadata
+
+	return &metadata, nil
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return set
+}
+
+// getNamespacesModTime returns the latest modification time of the given
+// namespace of root and of the namespaces it contains, up to depth levels.
+// Directories holding one of the repositories are not namespaces.
+func getNamespacesModTime(root, namespace string, depth int, repositories map[string]bool) (time.Time, error) {
+	dir := filepath.Join(root, namespace)
+
+	file, err := os.Stat(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	modTime := file.ModTime()
+	if depth <= 1 {
+		return modTime, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, file := range files {
+		name := path.Join(namespace, file.Name())
+		if !file.IsDir() || repositories[strings.TrimSuffix(name, ".git")] {
+			continue
+		}
+
+		t, err := getNamespacesModTime(root, name, depth-1, repositories)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if t.After(modTime) {
+			modTime = t
+		}
+	}
+
+	return modTime, nil
+}
+
+// getRepositoryNames returns the names of the repositories the user of r can
+// read.
+func (h *Handler) getRepositoryNames(r *http.Request) ([]string, error) {
+	names, err := h.listRepositoryNames(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var readable []string
+	for _, name := range names {
+		if h.canRead(r, name) {
+			readable = append(readable, name)
+		}
+	}
+
+	return readable, nil
+}
+
+// listRepositoryNames returns the names of the repositories found in the repo
+// root, which are listed again once a directory is added to or removed from
+// the root or one of its namespaces.
+func (h *Handler) listRepositoryNames(r *http.Request) ([]string, error) {
+	s := h.state(r)
+	root, depth := s.config.RepoRoot, s.config.RepoDepth
+
+	if s.config.Cache.Repositories <= 0 {
+		return git.GetRepositoryNames(root, depth)
+	}
+
+	s.caches.mu.Lock()
+	defer s.caches.mu.Unlock()
+
+	modTime, err := getNamespacesModTime(root, "", depth, toSet(s.caches.names))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.caches.names != nil && s.caches.namesModTime.Equal(modTime) {
+		return s.caches.names, nil
+	}
+
+	names, err := git.GetRepositoryNames(root, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	// The namespaces depend on the repositories which were found
+	modTime, err = getNamespacesModTime(root, "", depth, toSet(names))
+	if err != nil {
+		return nil, err
+	}
+
+	s.caches.names = names
+	s.caches.namesModTime = modTime
+
+	return names, nil
+}
+
+// getTreeObjects returns the objects of tree. As trees are immutable, the
+// listing is cached for as long as it is used.
+func (h *Handler) getTreeObjects(r *http.Request, tree *object.Tree) ([]*git.TreeObject, error) {
+	key := tree.Hash.String()
+
+	value, ok := h.caches(r).trees.Get(key)
+	h.metrics.lookup("trees", ok)
+	if ok {
+		return value.([]*git.TreeObject), nil
+	}
+
+	objects, err := git.GetTreeObjects(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	h.caches(r).trees.Add(key, objects, 1)
+
+	return objects, nil
+}
+
+// highlightBlob returns the highlighted contents of blob. The lexer depending
+// on the blob name, both the hash and the name of the blob are used as key.
+func (h *Handler) highlightBlob(r *http.Request, blob *git.Blob) (string, error) {
+	key := fmt.Sprintf("%s:%s", blob.Hash, blob.Name)
+
+	value, ok := h.caches(r).blobs.Get(key)
+	h.metrics.lookup("blobs", ok)
+	if ok {
+		return value.(string), nil
+	}
+
+	start := time.Now()
+	contents, err := util.Highlight(blob.Name, blob.Reader)
+	h.metrics.highlighted("blob", start)
+	if err != nil {
+		return "", err
+	}
+
+	h.caches(r).blobs.Add(key, contents, int64(len(contents)))
+
+	return contents, nil
+}
+
+// getLastCommits returns the last commits touching the tree found at path in
+// the tree of c and each of its entries. The cached commits are shared across
+// requests, so only their fields can be used: their storer belongs to the
+// repository opened by another request.
+func (h *Handler) getLastCommits(r *http.Request, repository *gogit.Repository, c *object.Commit, path string) (*git.LastCommits, error) {
+	key := fmt.Sprintf("%s:%s", c.Hash, path)
+
+	value, ok := h.caches(r).lastCommits.Get(key)
+	h.metrics.lookup("last_commits", ok)
+	if ok {
+		return value.(*git.LastCommits), nil
+	}
+
+	last, err := git.GetLastCommits(repository, c, path)
+	if err != nil {
+		return nil, err
+	}
+
+	h.caches(r).lastCommits.Add(key, last, 1)
+
+	return last, nil
+}
+
+// getComparison compares the head revision of repository to the base one,
+// listing up to limit commits. The cached comparisons are shared across
+// requests, so their commits are read again from repository.
+func (h *Handler) getComparison(r *http.Request, repository *gogit.Repository, base, head string, limit int) (*git.Comparison, error) {
+	baseCommit, err := git.ResolveRevision(repository, base)
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := git.ResolveRevision(repository, head)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s...%s:%d", baseCommit.Hash, headCommit.Hash, limit)
+
+	value, ok := h.caches(r).comparisons.Get(key)
+	h.metrics.lookup("comparisons", ok)
+	if ok {
+		return value.(*git.Comparison).Reload(repository)
+	}
+
+	cmp, err := git.CompareCommits(baseCommit, headCommit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	h.caches(r).comparisons.Add(key, cmp, 1)
+
+	return cmp, nil
+}
+
+// getLastCommit returns the last commit touching the blob found at path in the
+// tree of c. The cached commits of its parent tree are used.
+func (h *Handler) getLastCommit(r *http.Request, repository *gogit.Repository, c *object.Commit, path string) (*object.Commit, error) {
+	dir, name := filepath.Split(path)
+
+	last, err := h.getLastCommits(r, repository, c, strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	commit, ok := last.Entries[name]
+	if !ok {
+		return nil, object.ErrFileNotFound
+	}
+
+	return commit, nil
+}
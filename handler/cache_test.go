@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"bovarys.me/fudge/config"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+)
+
+func TestCaches(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Cache:    config.DefaultCacheConfig,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{
+		"/python/",
+		"/python/tree/master/src",
+		"/python/blob/master/src/hello.py",
+		"/python/blob/release/0.1/src/hello.py",
+	}
+
+	var wg sync.WaitGroup
+
+	// Requests are made concurrently to detect races when run with -race
+	for i := 0; i < 4; i++ {
+		for _, url := range urls {
+			wg.Add(1)
+
+			go func(url string) {
+				defer wg.Done()
+
+				request, err := http.NewRequest("GET", url, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				recorder := httptest.NewRecorder()
+				h.Router.ServeHTTP(recorder, request)
+
+				if recorder.Code != http.StatusOK {
+					t.Errorf("wrong status code for %s: got %v want %v",
+						url, recorder.Code, http.StatusOK)
+				}
+			}(url)
+		}
+	}
+
+	wg.Wait()
+
+	if h.caches(nil).repositories.Len() != 1 {
+		t.Errorf("wrong number of cached repositories: got %v want %v",
+			h.caches(nil).repositories.Len(), 1)
+	}
+
+	value, _ := h.caches(nil).repositories.Get("../git/testdata/repository/python")
+	pool := value.(*repositoryPool)
+	if len(pool.idle) == 0 {
+		t.Error("expected the opened repositories to be released")
+	}
+
+	for i := 0; i <= maxIdleRepositories; i++ {
+		pool.put(&gogit.Repository{}, pool.modTime)
+	}
+
+	if len(pool.idle) != maxIdleRepositories {
+		t.Errorf("wrong number of idle repositories: got %v want %v",
+			len(pool.idle), maxIdleRepositories)
+	}
+
+	// The root and src trees of master
+	if h.caches(nil).trees.Len() != 2 {
+		t.Errorf("wrong number of cached trees: got %v want %v",
+			h.caches(nil).trees.Len(), 2)
+	}
+
+	// hello.py is the same blob on both branches
+	if h.caches(nil).blobs.Len() != 1 {
+		t.Errorf("wrong number of cached blobs: got %v want %v",
+			h.caches(nil).blobs.Len(), 1)
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+)
+
+const uploadPackService = "git-upload-pack"
+
+// storerLoader loads the storer of an already opened repository, whatever the
+// endpoint.
+type storerLoader struct {
+	storer storer.Storer
+}
+
+func (l storerLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	return l.storer, nil
+}
+
+func newUploadPackSession(s storer.Storer) (transport.UploadPackSession, error) {
+	ep, err := transport.NewEndpoint("/")
+	if err != nil {
+		return nil, err
+	}
+
+	srv := server.NewServer(storerLoader{s})
+
+	return srv.NewUploadPackSession(ep, nil)
+}
+
+// decodeUploadPackRequest decodes the wants and the haves of an upload-pack
+// request, as go-git only decodes the wants. It reports whether the client is
+// done negotiating, i.e. whether it expects a pack.
+func decodeUploadPackRequest(r io.Reader) (*packp.UploadPackRequest, bool, error) {
+	req := packp.NewUploadPackRequest()
+
+	err := req.Decode(r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	done := false
+
+	scanner := pktline.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		if bytes.HasPrefix(line, []byte("have ")) {
+			hash := plumbing.NewHash(string(line[len("have "):]))
+			req.Haves = append(req.Haves, hash)
+		}
+
+		if bytes.Equal(line, []byte("done")) {
+			done = true
+			break
+		}
+	}
+
+	return req, done, scanner.Err()
+}
+
+// findCommonCommits returns the commits of haves found in s, which the client
+// and the server have in common.
+func findCommonCommits(s storer.EncodedObjectStorer, haves []plumbing.Hash) []plumbing.Hash {
+	var common []plumbing.Hash
+
+	for _, hash := range haves {
+		_, err := s.EncodedObject(plumbing.CommitObject, hash)
+		if err == nil {
+			common = append(common, hash)
+		}
+	}
+
+	return common
+}
+
+// newServerResponse returns the response to the haves of the client. As
+// multi_ack is not supported, only the first common commit is acknowledged,
+// and NAK is sent if there is none.
+func newServerResponse(common []plumbing.Hash) packp.ServerResponse {
+	if len(common) == 0 {
+		return packp.ServerResponse{}
+	}
+
+	return packp.ServerResponse{ACKs: common[:1]}
+}
+
+func setUploadPackHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type",
+		fmt.Sprintf("application/x-%s-result", uploadPackService))
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+// isCloneRequest reports whether r is made by Git to clone or fetch.
+func isCloneRequest(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/info/refs") ||
+		strings.HasSuffix(r.URL.Path, "/git-upload-pack")
+}
+
+func (h *Handler) advertiseRefs(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	if service != uploadPackService {
+		// Neither pushes nor the dumb HTTP protocol are supported
+		http.Error(w, "Only git-upload-pack is supported", http.StatusForbidden)
+		return
+	}
+
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	session, err := newUploadPackSession(repository.Storer)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer session.Close()
+
+	ar, err := session.AdvertisedReferences()
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	ar.Prefix = [][]byte{
+		[]byte("# service=" + uploadPackService),
+		pktline.Flush,
+	}
+
+	w.Header().Set("Content-Type",
+		fmt.Sprintf("application/x-%s-advertisement", uploadPackService))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ar.Encode(w)
+}
+
+func (h *Handler) uploadPack(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer reader.Close()
+
+		body = reader
+	}
+
+	req, done, err := decodeUploadPackRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The objects of haves missing from the repository cannot be left out of
+	// the pack
+	common := findCommonCommits(repository.Storer, req.Haves)
+	req.Haves = common
+
+	// Each negotiation round is a separate request with the smart HTTP
+	// protocol: the pack is only sent once the client is done
+	if !done {
+		setUploadPackHeaders(w)
+
+		response := newServerResponse(common)
+		response.Encode(w)
+		return
+	}
+
+	session, err := newUploadPackSession(repository.Storer)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer session.Close()
+
+	resp, err := session.UploadPack(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer resp.Close()
+
+	resp.ServerResponse = newServerResponse(common)
+
+	setUploadPackHeaders(w)
+
+	// The pack is streamed, so errors cannot be reported to the client once
+	// its first bytes have been sent
+	resp.Encode(w)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestDecodeUploadPackRequest(t *testing.T) {
+	body := "0032want fcd547424101b07adbd1e6cf4a06305342ae8f66\n" +
+		"0000" +
+		"0032have 3c255e3f5a626bc816102e91e2d8f8f94f733ed5\n" +
+		"0009done\n"
+
+	req, done, err := decodeUploadPackRequest(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !done {
+		t.Error("expected the request to be done")
+	}
+
+	if len(req.Wants) != 1 || req.Wants[0].String() != "fcd547424101b07adbd1e6cf4a06305342ae8f66" {
+		t.Errorf("wrong wants: got %v", req.Wants)
+	}
+
+	if len(req.Haves) != 1 || req.Haves[0].String() != "3c255e3f5a626bc816102e91e2d8f8f94f733ed5" {
+		t.Errorf("wrong haves: got %v", req.Haves)
+	}
+}
+
+func TestUploadPack(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "0032want fcd547424101b07adbd1e6cf4a06305342ae8f66\n0000"
+	common := "0032have 3c255e3f5a626bc816102e91e2d8f8f94f733ed5\n"
+	unknown := "0032have 0123456789012345678901234567890123456789\n"
+
+	tests := []struct {
+		body   string
+		prefix string
+	}{
+		{want + "0009done\n", "0008NAK\nPACK"},
+		{want + unknown + common + "0000", "0031ACK 3c255e3f5a626bc816102e91e2d8f8f94f733ed5\n"},
+		{want + unknown + "0000", "0008NAK\n"},
+		{want + unknown + common + "0009done\n",
+			"0031ACK 3c255e3f5a626bc816102e91e2d8f8f94f733ed5\nPACK"},
+		{want + unknown + "0009done\n", "0008NAK\nPACK"},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("POST", "/python/git-upload-pack",
+			strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		status := recorder.Code
+		if status != http.StatusOK {
+			t.Fatalf("wrong status code for %q: got %v want %v", test.body,
+				status, http.StatusOK)
+		}
+
+		got := recorder.Body.String()
+		if !strings.HasPrefix(got, test.prefix) {
+			t.Errorf("wrong response to %q: got %.40q want %q", test.body, got,
+				test.prefix)
+		}
+
+		// The pack is only sent once the client is done negotiating
+		if !strings.HasSuffix(test.prefix, "PACK") && got != test.prefix {
+			t.Errorf("expected no pack in response to %q, got %.40q", test.body, got)
+		}
+	}
+}
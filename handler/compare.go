@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bovarys.me/fudge/git"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// compareSeparator separates the base and head revisions of compare URLs.
+const compareSeparator = "..."
+
+// splitCompareSpec splits spec into its base and head revisions, reporting
+// whether it contains the separator.
+func splitCompareSpec(spec string) (string, string, bool) {
+	i := strings.Index(spec, compareSeparator)
+	if i == -1 {
+		return "", "", false
+	}
+
+	return spec[:i], spec[i+len(compareSeparator):], true
+}
+
+// redirectCompare redirects the compare form to the compare page of the base
+// and head query parameters, which default to the default revision.
+func (h *Handler) redirectCompare(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	base, head := query.Get("base"), query.Get("head")
+	if base == "" || head == "" {
+		metadata, err := h.getMetadata(r, vars["repository"])
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		rev, err := getDefaultRevision(metadata, repository)
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if base == "" {
+			base = rev
+		}
+		if head == "" {
+			head = rev
+		}
+	}
+
+	// Slashes are kept, revisions such as "release/1.0" being routed as is
+	u := &url.URL{Path: fmt.Sprintf("/%s/compare/%s%s%s", vars["repository"],
+		base, compareSeparator, head)}
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// showCompare shows the commits made on the head revision since it diverged
+// from the base revision, and the changes they introduced.
+func (h *Handler) showCompare(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	base, head, ok := splitCompareSpec(vars["spec"])
+	if !ok {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	cmp, err := h.getComparison(r, repository, base, head, maxComparedCommits)
+	if err == plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	patch, err := cmp.Patch()
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	files, err := h.highlightDiffs(patch)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	refs, err := git.GetRepositoryRefs(repository)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	var additions, deletions int
+	for _, file := range files {
+		additions += file.Additions
+		deletions += file.Deletions
+	}
+
+	params := h.getParams(r)
+
+	params["Base"] = base
+	params["Head"] = head
+	params["Refs"] = refs
+	params["Comparison"] = cmp
+	params["Diffs"] = files
+	params["DiffRev"] = cmp.Head.Hash.String()
+	params["Additions"] = additions
+	params["Deletions"] = deletions
+
+	h.state(r).tmpl["compare"].ExecuteTemplate(w, "layout", params)
+}
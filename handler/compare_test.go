@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bovarys.me/fudge/config"
+	"bovarys.me/fudge/git"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestCompare(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Cache:    config.DefaultCacheConfig,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url      string
+		status   int
+		location string
+		contains []string
+	}{
+		{"/python/compare/release/0.1...attributes", http.StatusOK, "", []string{
+			"/python/commit/2d7f83c799f68ea450efe9ba4e86e4320f8c0d19",
+			"/python/commit/fcd547424101b07adbd1e6cf4a06305342ae8f66",
+			`<a href="#diff-2">src/link.py</a>`,
+			"3 files changed",
+		}},
+		// The same comparison, read from the cache
+		{"/python/compare/3c255e3f5a626bc816102e91e2d8f8f94f733ed5...attributes",
+			http.StatusOK, "", []string{
+				"/python/commit/2d7f83c799f68ea450efe9ba4e86e4320f8c0d19",
+				"3 files changed",
+			}},
+		{"/python/compare/attributes...master", http.StatusOK, "", []string{
+			"master has no commits which are not in attributes.",
+		}},
+		{"/python/compare?base=v0.1.0&head=release/0.1", http.StatusFound,
+			"/python/compare/v0.1.0...release/0.1", nil},
+		{"/python/compare?base=v0.1.0", http.StatusFound,
+			"/python/compare/v0.1.0...master", nil},
+		{"/python/compare/nonexistent...master", http.StatusNotFound, "", nil},
+		{"/python/compare/master", http.StatusNotFound, "", nil},
+		{"/python/commits", http.StatusOK, "", []string{
+			`action="/python/compare"`,
+			`<option value="release/0.1">`,
+		}},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, recorder.Code, test.status)
+		}
+
+		location := recorder.Header().Get("Location")
+		if location != test.location {
+			t.Errorf("wrong location for %s: got %q want %q",
+				test.url, location, test.location)
+		}
+
+		body := recorder.Body.String()
+		for _, s := range test.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("expected %s to contain %q", test.url, s)
+			}
+		}
+	}
+
+	if h.caches(nil).comparisons.Len() != 2 {
+		t.Errorf("wrong number of cached comparisons: got %d want %d",
+			h.caches(nil).comparisons.Len(), 2)
+	}
+}
+
+func TestComparisonCache(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Cache:    config.DefaultCacheConfig,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compare := func(base, head string) *git.Comparison {
+		repository, err := git.OpenRepository(cfg.RepoRoot, "python", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cmp, err := h.getComparison(nil, repository, base, head, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return cmp
+	}
+
+	cached := compare("v0.1.0", "rename")
+	got := compare("v0.1.0", "rename")
+
+	if h.caches(nil).comparisons.Len() != 1 {
+		t.Errorf("wrong number of cached comparisons: got %d want %d",
+			h.caches(nil).comparisons.Len(), 1)
+	}
+
+	if got.MergeBase.Hash != cached.MergeBase.Hash || !got.Truncated ||
+		len(got.Commits) != len(cached.Commits) {
+		t.Fatalf("wrong cached comparison: got %+v want %+v", got, cached)
+	}
+
+	// The commits of the cached comparison belong to another repository
+	for i, c := range got.Commits {
+		if c.Hash != cached.Commits[i].Hash || c == cached.Commits[i] {
+			t.Errorf("commit %d was not read again: %s", i, c.Hash)
+		}
+	}
+
+	repository, err := git.OpenRepository(cfg.RepoRoot, "python", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = h.getComparison(nil, repository, "v0.1.0", "nonexistent", 2)
+	if err != plumbing.ErrReferenceNotFound {
+		t.Errorf("expected a missing reference error, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"bovarys.me/fudge/git"
+
+	"github.com/gorilla/mux"
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	// The number of entries of each feed
+	feedLength = 50
+	// The number of commits taken from each repository for the activity feed
+	activityLength = 20
+)
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author"`
+	Link    *atomLink   `xml:"link"`
+	Content *atomText   `xml:"content,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Links   []*atomLink  `xml:"link"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+func formatAtomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// getBaseURL returns the absolute URL fudge is served from, using the domain
+// config option or, if it is not set, the host of the request.
+func (h *Handler) getBaseURL(r *http.Request) string {
+	host := h.config(r).Domain
+	if host == "" {
+		host = r.Host
+	}
+
+	return "https://" + host
+}
+
+// newFeed returns a feed whose ID and self link is the URL of the request, and
+// whose alternate link is the page at path.
+func (h *Handler) newFeed(r *http.Request, title, path string) *atomFeed {
+	self := h.getBaseURL(r) + r.URL.Path
+
+	feed := &atomFeed{
+		ID:    self,
+		Title: title,
+		Links: []*atomLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: h.getBaseURL(r) + path, Rel: "alternate"},
+		},
+		Updated: formatAtomTime(time.Unix(0, 0)),
+	}
+
+	return feed
+}
+
+func (h *Handler) newCommitEntry(r *http.Request, repository string, c *object.Commit) *atomEntry {
+	link := fmt.Sprintf("%s/%s/commit/%s", h.getBaseURL(r), repository, c.Hash)
+
+	entry := &atomEntry{
+		ID:      link,
+		Title:   strings.SplitN(c.Message, "\n", 2)[0],
+		Updated: formatAtomTime(c.Committer.When),
+		Author: &atomPerson{
+			Name:  c.Author.Name,
+			Email: c.Author.Email,
+		},
+		Link: &atomLink{Href: link},
+		Content: &atomText{
+			Type: "text",
+			Body: c.Message,
+		},
+	}
+
+	return entry
+}
+
+func (h *Handler) sendFeed(w http.ResponseWriter, r *http.Request, feed *atomFeed) {
+	if len(feed.Entries) != 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+
+	_, err := w.Write([]byte(xml.Header))
+	if err != nil {
+		return
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	encoder.Encode(feed)
+}
+
+// getRecentCommits returns the most recent commits reachable from the default
+// revision of the repository with the given name.
+func (h *Handler) getRecentCommits(r *http.Request, name string, repository *gogit.Repository, limit int) ([]*object.Commit, error) {
+	metadata, err := h.getMetadata(r, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := getDefaultRevision(metadata, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := git.ResolveRevision(repository, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := git.GetRepositoryCommits(repository, head, &git.CommitsOptions{
+		Limit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Commits, nil
+}
+
+func (h *Handler) sendCommitsFeed(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	name := mux.Vars(r)["repository"]
+
+	commits, err := h.getRecentCommits(r, name, repository, feedLength)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	feed := h.newFeed(r, fmt.Sprintf("Recent commits to %s", name),
+		fmt.Sprintf("/%s/commits", name))
+
+	for _, commit := range commits {
+		feed.Entries = append(feed.Entries, h.newCommitEntry(r, name, commit))
+	}
+
+	h.sendFeed(w, r, feed)
+}
+
+func (h *Handler) sendTagsFeed(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	tags, err := git.GetRepositoryTags(repository)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	name := mux.Vars(r)["repository"]
+	feed := h.newFeed(r, fmt.Sprintf("Tags of %s", name),
+		fmt.Sprintf("/%s/", name))
+
+	for i, tag := range tags {
+		if i == feedLength {
+			break
+		}
+
+		link := fmt.Sprintf("%s/%s/tree/%s", h.getBaseURL(r), name, tag.Name)
+
+		entry := &atomEntry{
+			ID:      link,
+			Title:   tag.Name,
+			Updated: formatAtomTime(tag.Date()),
+			Author: &atomPerson{
+				Name:  tag.Commit.Author.Name,
+				Email: tag.Commit.Author.Email,
+			},
+			Link: &atomLink{Href: link},
+			Content: &atomText{
+				Type: "text",
+				Body: tag.Commit.Message,
+			},
+		}
+
+		if tag.Annotation != nil {
+			entry.Author.Name = tag.Annotation.Tagger.Name
+			entry.Author.Email = tag.Annotation.Tagger.Email
+			entry.Content.Body = tag.Annotation.Message
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	h.sendFeed(w, r, feed)
+}
+
+func (h *Handler) sendActivityFeed(w http.ResponseWriter, r *http.Request) {
+	repositories, err := h.getListedRepositories(r)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	type activity struct {
+		name   string
+		commit *object.Commit
+	}
+
+	var activities []*activity
+
+	for _, listed := range repositories {
+		repository, err := h.getRepository(r, listed.Name)
+		if err != nil {
+			h.Logger.Printf("Could not open %s: %s", listed.Name, err)
+			continue
+		}
+
+		commits, err := h.getRecentCommits(r, listed.Name, repository, activityLength)
+		if err != nil {
+			// Empty repositories have no activity
+			continue
+		}
+
+		for _, commit := range commits {
+			activities = append(activities, &activity{listed.Name, commit})
+		}
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].commit.Committer.When.After(
+			activities[j].commit.Committer.When)
+	})
+
+	feed := h.newFeed(r, "Recent activity", "/")
+
+	for i, a := range activities {
+		if i == feedLength {
+			break
+		}
+
+		entry := h.newCommitEntry(r, a.name, a.commit)
+		entry.Title = fmt.Sprintf("%s: %s", a.name, entry.Title)
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	h.sendFeed(w, r, feed)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestFeeds(t *testing.T) {
+	cfg := &config.Config{
+		Domain:   "fudge.example.org",
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url     string
+		id      string
+		titles  []string
+		updated string
+	}{
+		{
+			"/python/commits.atom",
+			"https://fudge.example.org/python/commit/fcd547424101b07adbd1e6cf4a06305342ae8f66",
+			[]string{"Edit README.md", "Add tests", "Initial commit"},
+			"",
+		},
+		{
+			"/python/tags.atom",
+			"https://fudge.example.org/python/tree/v0.2.0",
+			[]string{"v0.2.0", "v0.1.0"},
+			"2019-10-24T21:00:00Z",
+		},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		contentType := recorder.Header().Get("Content-Type")
+		if contentType != "application/atom+xml; charset=utf-8" {
+			t.Errorf("wrong content type for %s: got %q", test.url, contentType)
+		}
+
+		var feed atomFeed
+		err = xml.Unmarshal(recorder.Body.Bytes(), &feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(feed.Entries) != len(test.titles) {
+			t.Fatalf("wrong number of entries for %s: got %d want %d",
+				test.url, len(feed.Entries), len(test.titles))
+		}
+
+		for i, entry := range feed.Entries {
+			if entry.Title != test.titles[i] {
+				t.Errorf("wrong entry title for %s: got %q want %q",
+					test.url, entry.Title, test.titles[i])
+			}
+		}
+
+		if feed.Entries[0].ID != test.id {
+			t.Errorf("wrong entry ID for %s: got %q want %q",
+				test.url, feed.Entries[0].ID, test.id)
+		}
+
+		if test.updated != "" && feed.Entries[0].Updated != test.updated {
+			t.Errorf("wrong update date for %s: got %q want %q",
+				test.url, feed.Entries[0].Updated, test.updated)
+		}
+	}
+}
@@ -4,92 +4,242 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"bovarys.me/fudge/config"
 	"bovarys.me/fudge/git"
-	"bovarys.me/fudge/logger"
 	"bovarys.me/fudge/util"
 
-	"github.com/gorilla/handlers"
+	"github.com/dustin/go-humanize"
 	"github.com/gorilla/mux"
 	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
-type Handler struct {
-	Router http.Handler
+const (
+	defaultCommitsPerPage = 50
+	maxCommitsPerPage     = 500
+	maxComparedCommits    = 250
+	// The number of idle copies of each repository kept opened
+	maxIdleRepositories = 4
+)
+
+type fileDiff struct {
+	*git.FileDiff
+	Contents template.HTML // The highlighted file diff
+}
+
+type blameLine struct {
+	*git.BlameLine
+	Number   int
+	Contents template.HTML // The highlighted line
+}
+
+type namespace struct {
+	Name         string // The namespace path or section, empty for the repo root
+	Repositories []*namespacedRepository
+}
+
+type namespacedRepository struct {
+	*listedRepository
+	ShortName string // The repository name within its namespace, e.g. "project"
+}
+
+// groupByNamespace groups repositories by section, or by namespace if they
+// have none, the repo root first. Repositories are shown with their full name
+// within sections.
+func groupByNamespace(repositories []*listedRepository) []*namespace {
+	namespaces := make(map[string]*namespace)
+	var groups []*namespace
+
+	for _, repository := range repositories {
+		dir, base := path.Split(repository.Name)
+		dir = strings.TrimSuffix(dir, "/")
+
+		if repository.Section != "" {
+			dir, base = repository.Section, repository.Name
+		}
+
+		group, ok := namespaces[dir]
+		if !ok {
+			group = &namespace{Name: dir}
+			namespaces[dir] = group
+			groups = append(groups, group)
+		}
+
+		group.Repositories = append(group.Repositories, &namespacedRepository{
+			listedRepository: repository,
+			ShortName:        base,
+		})
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
 
-	config *config.Config
-	tmpl   map[string]*template.Template
+	return groups
+}
+
+// The pages parsed from the template directory
+var pages = []string{"home", "commits", "commit", "compare", "tags", "tree",
+	"blob", "blame", "404", "500"}
+
+var funcs = template.FuncMap{
+	// subject returns the first line of a commit message
+	"subject": func(message string) string {
+		return strings.SplitN(message, "\n", 2)[0]
+	},
+	// since returns the time elapsed since t in a human readable form
+	"since": humanize.Time,
+}
+
+type Handler struct {
+	Router  http.Handler
+	Metrics http.Handler // Serves the metrics in the Prometheus text format
+	Logger  *log.Logger  // Logs the errors which cannot be sent to clients
+
+	router              *mux.Router
+	repositoryRouter    *mux.Router // Routes the paths within repositories
+	apiRepositoryRouter *mux.Router // Routes the API paths within repositories
+	metrics             *handlerMetrics
+	mu                  sync.Mutex   // Serializes reloads
+	current             atomic.Value // The current *state
 }
 
 func NewHandler(cfg *config.Config) (*Handler, error) {
 	h := &Handler{
-		config: cfg,
-		tmpl:   make(map[string]*template.Template),
+		Logger:  log.New(os.Stderr, "", log.LstdFlags),
+		metrics: newHandlerMetrics(),
 	}
 
+	h.Metrics = h.metrics.handler
+
+	h.repositoryRouter = mux.NewRouter()
+	h.setRepositoryRoutes(h.repositoryRouter)
+
 	router := mux.NewRouter()
 	router.StrictSlash(true)
+	router.Use(h.recordRoute)
+	router.Use(h.authenticate)
+	router.Use(h.releaseRepositories)
 
-	static := http.StripPrefix("/static/", http.FileServer(http.Dir("static")))
-	router.PathPrefix("/static/").Handler(static)
+	router.PathPrefix("/static/").HandlerFunc(h.serveStatic)
 
-	router.HandleFunc("/", h.showHome)
-	router.HandleFunc("/{repository}/", h.showTree)
-	router.HandleFunc("/{repository}/commits", h.showCommits)
-	router.HandleFunc("/{repository}/tree/{path:.*}", h.showTree)
-	router.HandleFunc("/{repository}/blob/{path:.*}", h.showBlob)
-	router.HandleFunc("/{repository}/raw/{path:.*}", h.sendBlob)
-
-	h.Router = router
+	h.setAPIRoutes(router)
 
-	err := h.setLoggers()
+	router.HandleFunc("/", h.showHome)
+	router.HandleFunc("/login", h.login)
+	router.HandleFunc("/metrics", h.serveMetrics)
+	router.HandleFunc("/healthz", h.sendHealth)
+	router.HandleFunc("/readyz", h.sendReadiness)
+	router.HandleFunc("/syntax/{theme}.css", h.sendSyntaxCSS)
+	router.HandleFunc("/theme", h.setTheme).Methods("POST")
+	router.HandleFunc("/activity.atom", h.sendActivityFeed)
+	// As repository names can contain slashes, this route must come last
+	router.PathPrefix("/").HandlerFunc(h.serveRepository)
+
+	h.router = router
+	h.Router = http.HandlerFunc(h.serveHTTP)
+
+	s, err := h.newState(cfg, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	pages := []string{"home", "commits", "tree", "blob", "404", "500"}
+	h.current.Store(s)
+
+	return h, nil
+}
+
+// parseTemplates parses the template of each page found in files.
+func parseTemplates(files fs.FS) (map[string]*template.Template, error) {
+	tmpl := make(map[string]*template.Template)
+
 	for _, page := range pages {
 		path := fmt.Sprintf("template/%s.html", page)
 
-		t, err := template.ParseFiles(
+		t, err := template.New(page).Funcs(funcs).ParseFS(files,
 			"template/_layout.html", "template/_utils.html", path)
 		if err != nil {
 			return nil, err
 		}
 
-		h.tmpl[page] = t
+		tmpl[page] = t
 	}
 
-	return h, nil
+	return tmpl, nil
 }
 
-func (h *Handler) setLoggers() error {
-	loggerConfig, ok := h.config.Loggers["router"]
-	if !ok {
-		return nil
-	}
+func (h *Handler) serveStatic(w http.ResponseWriter, r *http.Request) {
+	h.state(r).static.ServeHTTP(w, r)
+}
 
-	if !loggerConfig.Enable {
-		return nil
-	}
+func (h *Handler) openRepository(w http.ResponseWriter, r *http.Request) (*gogit.Repository, error) {
+	vars := mux.Vars(r)
 
-	writer, err := logger.Writer(loggerConfig)
+	repository, err := h.getRepository(r, vars["repository"])
+	if err == gogit.ErrRepositoryNotExists {
+		h.sendRepositoryNotFound(w, r)
+		return nil, err
+	}
 	if err != nil {
-		return err
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return nil, err
 	}
 
-	h.Router = handlers.CombinedLoggingHandler(writer, h.Router)
+	return repository, nil
+}
+
+// sendRepositoryNotFound answers requests made to a missing repository.
+func (h *Handler) sendRepositoryNotFound(w http.ResponseWriter, r *http.Request) {
+	if isCloneRequest(r) && getUser(r) == "" && h.state(r).auth.Enabled() {
+		// Git only sends credentials once asked for them. Anonymous clones of
+		// missing repositories are challenged too, so as not to reveal the
+		// private ones.
+		h.requireAuthentication(w, r)
+		return
+	}
 
-	return nil
+	h.showError(w, r, http.StatusNotFound, nil)
 }
 
-func (h *Handler) openRepository(w http.ResponseWriter, r *http.Request) (*gogit.Repository, error) {
+// resolveRevision resolves the revision and path found in the spec variable
+// of the request, or the default revision of the repository if there is none.
+// The rev and path variables of the request are set accordingly. The metadata
+// of the repository is read for its default branch, unless it is given.
+func (h *Handler) resolveRevision(w http.ResponseWriter, r *http.Request, repository *gogit.Repository, metadata *git.Metadata) (*object.Commit, error) {
 	vars := mux.Vars(r)
 
-	repository, err := git.OpenRepository(h.config.RepoRoot, vars["repository"], false)
-	if err == gogit.ErrRepositoryNotExists {
+	var rev, path string
+	var commit *object.Commit
+	var err error
+
+	if vars["spec"] != "" {
+		rev, path, commit, err = git.SplitRevision(repository, vars["spec"])
+	} else {
+		if metadata == nil {
+			metadata, err = h.getMetadata(r, vars["repository"])
+		}
+		if err == nil {
+			rev, err = getDefaultRevision(metadata, repository)
+		}
+		if err == nil {
+			commit, err = git.ResolveRevision(repository, rev)
+		}
+	}
+	if err == plumbing.ErrReferenceNotFound {
 		h.showError(w, r, http.StatusNotFound, nil)
 		return nil, err
 	}
@@ -98,47 +248,115 @@ func (h *Handler) openRepository(w http.ResponseWriter, r *http.Request) (*gogit
 		return nil, err
 	}
 
-	return repository, nil
+	vars["rev"] = rev
+	vars["path"] = path
+
+	return commit, nil
+}
+
+// getCommitsOptions returns the pagination options found in the query string
+// of the request: the after and before cursors, and the page size n.
+func getCommitsOptions(r *http.Request) *git.CommitsOptions {
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("n"))
+	if err != nil || limit <= 0 {
+		limit = defaultCommitsPerPage
+	}
+	if limit > maxCommitsPerPage {
+		limit = maxCommitsPerPage
+	}
+
+	opts := &git.CommitsOptions{
+		After:  plumbing.NewHash(query.Get("after")),
+		Before: plumbing.NewHash(query.Get("before")),
+		Limit:  limit,
+	}
+
+	return opts
+}
+
+// getCloneURL returns the URL a repository can be cloned from: the one served
+// by the public Git server if the git-url option is set, or the one served by
+// fudge itself otherwise.
+func (h *Handler) getCloneURL(r *http.Request, repository string) string {
+	if repository == "" {
+		return ""
+	}
+
+	if h.config(r).GitURL != "" {
+		return fmt.Sprintf("%s/%s", h.config(r).GitURL, repository)
+	}
+
+	if h.config(r).Domain != "" {
+		return fmt.Sprintf("https://%s/%s", h.config(r).Domain, repository)
+	}
+
+	return ""
 }
 
 func (h *Handler) getParams(r *http.Request) map[string]interface{} {
 	vars := mux.Vars(r)
 
 	repository := vars["repository"]
+	rev := vars["rev"]
 	path := vars["path"]
 
 	params := make(map[string]interface{})
 
-	params["Domain"] = h.config.Domain
-	params["GitURL"] = h.config.GitURL
+	params["Domain"] = h.config(r).Domain
+	params["GitURL"] = h.config(r).GitURL
+	params["CloneURL"] = h.getCloneURL(r, repository)
 	params["RepoName"] = repository
+	params["Rev"] = rev
 	params["Path"] = path
+	params["Theme"] = getTheme(r)
+	params["Return"] = r.URL.RequestURI()
+	params["User"] = getUser(r)
+	params["Login"] = h.state(r).auth.Enabled()
 
 	if repository != "" {
-		params["Breadcrumbs"] = util.Breadcrumbs(repository, path)
+		params["Breadcrumbs"] = util.Breadcrumbs(repository, rev, path)
+		params["Feeds"] = map[string]string{
+			"Commits": fmt.Sprintf("/%s/commits.atom", repository),
+			"Tags":    fmt.Sprintf("/%s/tags.atom", repository),
+		}
+	} else {
+		params["Feeds"] = map[string]string{
+			"Recent activity": "/activity.atom",
+		}
 	}
 
 	return params
 }
 
 func (h *Handler) showError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if isAPIRequest(r) {
+		h.sendAPIError(w, r, status, err)
+		return
+	}
+
 	w.WriteHeader(status)
 
 	switch status {
 	case http.StatusNotFound:
-		h.tmpl["404"].ExecuteTemplate(w, "layout", nil)
+		params := map[string]interface{}{
+			"Theme": getTheme(r),
+		}
+
+		h.state(r).tmpl["404"].ExecuteTemplate(w, "layout", params)
 	case http.StatusInternalServerError:
 		params := h.getParams(r)
 
-		params["Debug"] = h.config.Debug
+		params["Debug"] = h.config(r).Debug
 		params["Error"] = err.Error()
 
-		h.tmpl["500"].ExecuteTemplate(w, "layout", params)
+		h.state(r).tmpl["500"].ExecuteTemplate(w, "layout", params)
 	}
 }
 
 func (h *Handler) showHome(w http.ResponseWriter, r *http.Request) {
-	names, err := git.GetRepositoryNames(h.config.RepoRoot)
+	repositories, err := h.getListedRepositories(r)
 	if err != nil {
 		h.showError(w, r, http.StatusInternalServerError, err)
 		return
@@ -146,10 +364,9 @@ func (h *Handler) showHome(w http.ResponseWriter, r *http.Request) {
 
 	params := h.getParams(r)
 
-	params["Names"] = names
-	params["Descriptions"] = h.config.Descriptions
+	params["Namespaces"] = groupByNamespace(repositories)
 
-	h.tmpl["home"].ExecuteTemplate(w, "layout", params)
+	h.state(r).tmpl["home"].ExecuteTemplate(w, "layout", params)
 }
 
 func (h *Handler) showCommits(w http.ResponseWriter, r *http.Request) {
@@ -158,7 +375,100 @@ func (h *Handler) showCommits(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	commits, err := git.GetRepositoryCommits(repository)
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	opts := getCommitsOptions(r)
+	opts.Path = vars["path"]
+
+	page, err := git.GetRepositoryCommits(repository, commit, opts)
+	if err == plumbing.ErrObjectNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	refs, err := git.GetRepositoryRefs(repository)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	params := h.getParams(r)
+
+	params["Page"] = page
+	params["Refs"] = refs
+	params["Head"] = vars["rev"]
+	if opts.Limit != defaultCommitsPerPage {
+		params["Limit"] = opts.Limit
+	}
+
+	h.state(r).tmpl["commits"].ExecuteTemplate(w, "layout", params)
+}
+
+// highlightDiffs splits patch into one highlighted diff per changed file.
+func (h *Handler) highlightDiffs(patch *object.Patch) ([]*fileDiff, error) {
+	diffs, err := git.GetFileDiffs(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*fileDiff
+
+	for _, diff := range diffs {
+		contents := ""
+		if !diff.IsBinary {
+			start := time.Now()
+			contents, err = util.HighlightDiff(diff.Patch)
+			h.metrics.highlighted("diff", start)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		file := &fileDiff{
+			FileDiff: diff,
+			Contents: template.HTML(contents),
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func (h *Handler) showCommit(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	commit, err := git.ResolveRevision(repository, vars["hash"])
+	if err == plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	patch, err := git.GetCommitPatch(commit)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	files, err := h.highlightDiffs(patch)
 	if err != nil {
 		h.showError(w, r, http.StatusInternalServerError, err)
 		return
@@ -166,9 +476,11 @@ func (h *Handler) showCommits(w http.ResponseWriter, r *http.Request) {
 
 	params := h.getParams(r)
 
-	params["Commits"] = commits
+	params["Commit"] = commit
+	params["Diffs"] = files
+	params["DiffRev"] = commit.Hash.String()
 
-	h.tmpl["commits"].ExecuteTemplate(w, "layout", params)
+	h.state(r).tmpl["commit"].ExecuteTemplate(w, "layout", params)
 }
 
 func (h *Handler) showTree(w http.ResponseWriter, r *http.Request) {
@@ -179,19 +491,43 @@ func (h *Handler) showTree(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 
-	tree, err := git.GetRepositoryTree(repository, vars["path"])
+	metadata, err := h.getMetadata(r, vars["repository"])
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	commit, err := h.resolveRevision(w, r, repository, metadata)
+	if err != nil {
+		return
+	}
+
+	tree, err := git.GetRepositoryTree(commit, vars["path"])
 	if err != nil {
 		h.showError(w, r, http.StatusNotFound, nil)
 		return
 	}
 
-	objects, err := git.GetTreeObjects(tree)
+	objects, err := h.getTreeObjects(r, tree)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	last, err := h.getLastCommits(r, repository, commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	readme, err := getReadme(vars["repository"], vars["rev"], vars["path"],
+		commit, objects)
 	if err != nil {
 		h.showError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	commit, err := git.GetRepositoryLastCommit(repository)
+	refs, err := git.GetRepositoryRefs(repository)
 	if err != nil {
 		h.showError(w, r, http.StatusInternalServerError, err)
 		return
@@ -199,10 +535,26 @@ func (h *Handler) showTree(w http.ResponseWriter, r *http.Request) {
 
 	params := h.getParams(r)
 
-	params["LastCommit"] = commit
+	// The latest release is only shown on the repository page
+	if vars["path"] == "" {
+		tags, err := git.GetRepositoryTags(repository)
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		params["LatestRelease"] = git.GetLatestRelease(tags)
+	}
+
+	params["View"] = "tree"
+	params["Metadata"] = metadata
+	params["Refs"] = refs
+	params["LastCommit"] = last.Tree
+	params["LastCommits"] = last.Entries
 	params["Objects"] = objects
+	params["Readme"] = readme
 
-	h.tmpl["tree"].ExecuteTemplate(w, "layout", params)
+	h.state(r).tmpl["tree"].ExecuteTemplate(w, "layout", params)
 }
 
 func (h *Handler) showBlob(w http.ResponseWriter, r *http.Request) {
@@ -211,9 +563,14 @@ func (h *Handler) showBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
 	vars := mux.Vars(r)
 
-	blob, err := git.GetRepositoryBlob(repository, vars["path"])
+	blob, err := git.GetRepositoryBlob(commit, vars["path"])
 	if err != nil {
 		h.showError(w, r, http.StatusNotFound, nil)
 		return
@@ -221,14 +578,20 @@ func (h *Handler) showBlob(w http.ResponseWriter, r *http.Request) {
 
 	contents := ""
 	if !blob.IsBinary {
-		contents, err = util.Highlight(blob.Name, blob.Reader)
+		contents, err = h.highlightBlob(r, blob)
 		if err != nil {
 			h.showError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
 
-	commit, err := git.GetRepositoryLastCommit(repository)
+	refs, err := git.GetRepositoryRefs(repository)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	last, err := h.getLastCommit(r, repository, commit, vars["path"])
 	if err != nil {
 		h.showError(w, r, http.StatusInternalServerError, err)
 		return
@@ -236,11 +599,85 @@ func (h *Handler) showBlob(w http.ResponseWriter, r *http.Request) {
 
 	params := h.getParams(r)
 
-	params["LastCommit"] = commit
+	params["View"] = "blob"
+	params["Refs"] = refs
+	params["LastCommit"] = last
 	params["Blob"] = blob
 	params["Contents"] = template.HTML(contents)
 
-	h.tmpl["blob"].ExecuteTemplate(w, "layout", params)
+	h.state(r).tmpl["blob"].ExecuteTemplate(w, "layout", params)
+}
+
+func (h *Handler) showBlame(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	blob, err := git.GetRepositoryBlob(commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	defer blob.Reader.Close()
+
+	var lines []*blameLine
+
+	if !blob.IsBinary {
+		b, err := ioutil.ReadAll(blob.Reader)
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		start := time.Now()
+		contents, err := util.HighlightLines(blob.Name, string(b))
+		h.metrics.highlighted("lines", start)
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		blame, err := git.GetBlame(commit, vars["path"])
+		if err != nil {
+			h.showError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		for i, line := range blame {
+			l := &blameLine{
+				BlameLine: line,
+				Number:    i + 1,
+			}
+
+			if i < len(contents) {
+				l.Contents = template.HTML(contents[i])
+			}
+
+			lines = append(lines, l)
+		}
+	}
+
+	last, err := h.getLastCommit(r, repository, commit, vars["path"])
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	params := h.getParams(r)
+
+	params["LastCommit"] = last
+	params["Blob"] = blob
+	params["Lines"] = lines
+
+	h.state(r).tmpl["blame"].ExecuteTemplate(w, "layout", params)
 }
 
 func (h *Handler) sendBlob(w http.ResponseWriter, r *http.Request) {
@@ -249,9 +686,14 @@ func (h *Handler) sendBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	commit, err := h.resolveRevision(w, r, repository, nil)
+	if err != nil {
+		return
+	}
+
 	vars := mux.Vars(r)
 
-	blob, err := git.GetRepositoryBlob(repository, vars["path"])
+	blob, err := git.GetRepositoryBlob(commit, vars["path"])
 	if err != nil {
 		h.showError(w, r, http.StatusNotFound, nil)
 		return
@@ -264,9 +706,74 @@ func (h *Handler) sendBlob(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", value)
 
-	_, err = io.Copy(w, blob.Reader)
+	n, err := io.Copy(w, blob.Reader)
+	h.metrics.blobBytes.Add(float64(n))
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func (h *Handler) sendArchive(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	var rev, format string
+	for _, f := range git.ArchiveFormats {
+		if strings.HasSuffix(vars["spec"], "."+f) {
+			rev = strings.TrimSuffix(vars["spec"], "."+f)
+			format = f
+		}
+	}
+
+	if format == "" {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	commit, err := git.ResolveRevision(repository, rev)
+	if err == plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	archive, err := git.NewArchive(commit, format)
 	if err != nil {
 		h.showError(w, r, http.StatusInternalServerError, err)
 		return
 	}
+
+	// Revisions such as "release/1.0" cannot be used as is in a filename
+	name := fmt.Sprintf("%s-%s", path.Base(vars["repository"]),
+		strings.Replace(rev, "/", "-", -1))
+
+	value := "application/gzip"
+	if format == git.ArchiveZip {
+		value = "application/zip"
+	}
+
+	w.Header().Set("Content-Type", value)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", name+"."+format))
+
+	// The archive is streamed, so errors cannot be reported to the client once
+	// its first bytes have been sent
+	err = archive.Write(w, name+"/")
+	if err != nil {
+		h.logError(r, err)
+	}
+}
+
+// logError logs an error which occurred while r was being answered, once it
+// was too late to send an error page.
+func (h *Handler) logError(r *http.Request, err error) {
+	h.Logger.Printf("Could not answer %s %s: %s", r.Method, r.URL.Path, err)
 }
@@ -1,25 +1,20 @@
 package handler
 
 import (
+	"bytes"
+	"errors"
+	"log"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 
 	"bovarys.me/fudge/config"
 )
 
-func init() {
-	err := os.Chdir("..")
-	if err != nil {
-		panic(err)
-	}
-}
-
 func TestRepositoryNotFound(t *testing.T) {
 	cfg := &config.Config{
-		RepoRoot: "../",
+		RepoRoot: "../../",
 		Debug:    true,
 	}
 
@@ -48,3 +43,192 @@ func TestRepositoryNotFound(t *testing.T) {
 		t.Error("body does not contains 'Page not found'")
 	}
 }
+
+func TestRevisions(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/python/", http.StatusOK},
+		{"/python/tree/master/src", http.StatusOK},
+		{"/python/tree/release/0.1/tests", http.StatusOK},
+		{"/python/tree/nonexistent/src", http.StatusNotFound},
+		{"/python/blob/release/0.1/README.md", http.StatusOK},
+		{"/python/raw/8018d114b13d3b65862d450cf77189344ac094c1/src/hello.py", http.StatusOK},
+		{"/python/commits/release/0.1", http.StatusOK},
+		{"/python/commits?after=3c255e3f5a626bc816102e91e2d8f8f94f733ed5&n=1", http.StatusOK},
+		{"/python/commits?after=0123", http.StatusNotFound},
+		{"/python/log/rename/src/main.py", http.StatusOK},
+		{"/python/blame/master/README.md", http.StatusOK},
+		{"/python/blame/master/nonexistent", http.StatusNotFound},
+		{"/python/archive/release/0.1.tar.gz", http.StatusOK},
+		{"/python/archive/master.zip", http.StatusOK},
+		{"/python/archive/master.rar", http.StatusNotFound},
+		{"/python/archive/nonexistent.zip", http.StatusNotFound},
+		{"/python/info/refs?service=git-upload-pack", http.StatusOK},
+		{"/python/info/refs?service=git-receive-pack", http.StatusForbidden},
+		{"/python/info/refs", http.StatusForbidden},
+		{"/python/commit/8018d114b13d3b65862d450cf77189344ac094c1", http.StatusOK},
+		{"/python/commit/nonexistent", http.StatusNotFound},
+		{"/python/commits.atom", http.StatusOK},
+		{"/python/tags.atom", http.StatusOK},
+		{"/nonexistent/tags.atom", http.StatusNotFound},
+		{"/activity.atom", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		status := recorder.Code
+		if status != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, status, test.status)
+		}
+	}
+}
+
+func TestReadme(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := http.NewRequest("GET", "/python/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, request)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, "<h1>README.md</h1>") {
+		t.Error("body does not contain the rendered README")
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot:  "../git/testdata",
+		RepoDepth: 2,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/repository/python/", http.StatusOK},
+		{"/repository/python/tree/release/0.1/tests", http.StatusOK},
+		{"/repository/python/blob/master/src/hello.py", http.StatusOK},
+		{"/repository/python/commits", http.StatusOK},
+		{"/repository/python/log/rename/src/main.py", http.StatusOK},
+		{"/repository/python/archive/master.zip", http.StatusOK},
+		{"/repository/python/info/refs?service=git-upload-pack", http.StatusOK},
+		{"/api/v1/repos/repository/python", http.StatusOK},
+		{"/api/v1/repos/repository/python/tree/master/src", http.StatusOK},
+		{"/repository/", http.StatusNotFound},
+		{"/repository/nonexistent/", http.StatusNotFound},
+		{"/python/", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		status := recorder.Code
+		if status != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, status, test.status)
+		}
+
+		if test.url == "/repository/python/archive/master.zip" {
+			want := `attachment; filename="python-master.zip"`
+			got := recorder.Header().Get("Content-Disposition")
+			if got != want {
+				t.Errorf("wrong content disposition: got %s want %s", got, want)
+			}
+		}
+	}
+
+	request, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, request)
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`<h3 class="namespace">repositories</h3>`,
+		`<a href="/repository/python/">python</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("home page does not contain %s", want)
+		}
+	}
+}
+
+// failingWriter fails to write the response body.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestArchiveErrors(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := new(bytes.Buffer)
+	h.Logger = log.New(logs, "", 0)
+
+	request, err := http.NewRequest("GET", "/python/archive/master.tar.gz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Router.ServeHTTP(failingWriter{httptest.NewRecorder()}, request)
+
+	want := "Could not answer GET /python/archive/master.tar.gz: connection reset"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("expected the error to be logged, got %q", logs)
+	}
+}
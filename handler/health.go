@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"bovarys.me/fudge/git"
+)
+
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"` // Check names to "ok" or errors
+}
+
+// sendHealth reports that fudge is alive, as long as it serves requests.
+func (h *Handler) sendHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// checkRepoRoot reports whether the repo root can be listed.
+func checkRepoRoot(root string) error {
+	dir, err := os.Open(root)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	_, err = dir.Readdirnames(1)
+	if err == io.EOF {
+		return nil
+	}
+
+	return err
+}
+
+// checkTemplates reports whether every page was parsed.
+func (h *Handler) checkTemplates(r *http.Request) error {
+	tmpl := h.state(r).tmpl
+
+	for _, page := range pages {
+		if tmpl[page] == nil {
+			return fmt.Errorf("template not parsed: %s", page)
+		}
+	}
+
+	return nil
+}
+
+// sendReadiness reports whether fudge can serve repositories: the repo root
+// must be readable, the templates parsed, and the health repository, if set,
+// must open.
+func (h *Handler) sendReadiness(w http.ResponseWriter, r *http.Request) {
+	cfg := h.config(r)
+
+	checks := map[string]error{
+		"repo-root": checkRepoRoot(cfg.RepoRoot),
+		"templates": h.checkTemplates(r),
+	}
+
+	if cfg.Health.Repository != "" {
+		_, err := git.OpenRepository(cfg.RepoRoot, cfg.Health.Repository, false)
+		checks["repository"] = err
+	}
+
+	status := http.StatusOK
+	result := &readiness{
+		Status: "ok",
+		Checks: make(map[string]string),
+	}
+
+	for name, err := range checks {
+		result.Checks[name] = "ok"
+
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			result.Status = "error"
+			result.Checks[name] = err.Error()
+		}
+	}
+
+	h.sendJSON(w, status, result)
+}
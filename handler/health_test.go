@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Health:   config.HealthConfig{Repository: "python"},
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	recorder := get("/healthz")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "ok\n" {
+		t.Errorf("wrong health response: got %v %q", recorder.Code,
+			recorder.Body.String())
+	}
+
+	tests := []struct {
+		root       string
+		repository string
+		status     int
+		failed     string // The failed check, if any
+	}{
+		{"../git/testdata/repository", "python", http.StatusOK, ""},
+		{"../git/testdata/repository", "", http.StatusOK, ""},
+		{"../git/testdata/repository", "nonexistent", http.StatusServiceUnavailable, "repository"},
+		{dir, "", http.StatusServiceUnavailable, "repo-root"},
+	}
+
+	for _, test := range tests {
+		cfg.RepoRoot = test.root
+		cfg.Health.Repository = test.repository
+
+		// The repo root is removed once the handler was created
+		if test.root == dir {
+			os.RemoveAll(dir)
+		}
+
+		recorder := get("/readyz")
+		if recorder.Code != test.status {
+			t.Errorf("wrong readiness status for %+v: got %v want %v",
+				test, recorder.Code, test.status)
+		}
+
+		var result readiness
+
+		err := json.Unmarshal(recorder.Body.Bytes(), &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, check := range result.Checks {
+			if (check != "ok") != (name == test.failed) {
+				t.Errorf("wrong %s check for %+v: got %q", name, test, check)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"bovarys.me/fudge/git"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// listedRepository is a repository shown on listings, with its metadata.
+type listedRepository struct {
+	Name string
+	*git.Metadata
+}
+
+// getMetadata returns the metadata of the repository with the given name.
+// The options set in the config take precedence over the ones read from the
+// repository.
+func (h *Handler) getMetadata(r *http.Request, name string) (*git.Metadata, error) {
+	cfg := h.config(r)
+
+	path, err := git.FindRepository(cfg.RepoRoot, name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := h.getRepositoryMetadata(r, path)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSuffix(name, ".git")
+
+	if description := cfg.Descriptions[name]; description != "" {
+		metadata.Description = description
+	}
+
+	override, ok := cfg.Repositories[name]
+	if !ok {
+		return metadata, nil
+	}
+
+	for _, field := range []struct {
+		value    string
+		metadata *string
+	}{
+		{override.Description, &metadata.Description},
+		{override.Owner, &metadata.Owner},
+		{override.Homepage, &metadata.Homepage},
+		{override.DefaultBranch, &metadata.DefaultBranch},
+		{override.Section, &metadata.Section},
+	} {
+		if field.value != "" {
+			*field.metadata = field.value
+		}
+	}
+
+	if override.Hidden != nil {
+		metadata.Hidden = *override.Hidden
+	}
+
+	return metadata, nil
+}
+
+// getListedRepositories returns the repositories the user of r can read,
+// leaving out the hidden ones. Repositories whose metadata cannot be read are
+// logged and left out too, so that they do not prevent the others from being
+// listed.
+func (h *Handler) getListedRepositories(r *http.Request) ([]*listedRepository, error) {
+	names, err := h.getRepositoryNames(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var repositories []*listedRepository
+	for _, name := range names {
+		metadata, err := h.getMetadata(r, name)
+		if err != nil {
+			h.Logger.Printf("Could not read the metadata of %s: %s", name, err)
+			continue
+		}
+
+		if !metadata.Hidden {
+			repositories = append(repositories, &listedRepository{name, metadata})
+		}
+	}
+
+	return repositories, nil
+}
+
+// getDefaultRevision returns the default branch of the repository set in its
+// metadata if it exists, or the revision pointed to by HEAD otherwise.
+func getDefaultRevision(metadata *git.Metadata, repository *gogit.Repository) (string, error) {
+	if metadata.DefaultBranch != "" {
+		_, err := repository.Reference(plumbing.NewBranchReferenceName(metadata.DefaultBranch), true)
+		if err == nil {
+			return metadata.DefaultBranch, nil
+		}
+	}
+
+	return git.GetDefaultRevision(repository)
+}
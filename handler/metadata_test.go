@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestMetadata(t *testing.T) {
+	cfg := &config.Config{
+		Domain:    "fudge.example.org",
+		RepoRoot:  "../git/testdata/repositories",
+		RepoDepth: 3,
+		Repositories: map[string]config.RepositoryConfig{
+			"team/project": {Owner: "John Doe"},
+		},
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	body := get("/").Body.String()
+	for _, want := range []string{
+		`<h3 class="namespace">Projects</h3>`,
+		`<a href="/team/project/">team/project</a>`,
+		"A namespaced project",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("home page does not contain %s", want)
+		}
+	}
+
+	if strings.Contains(body, "team/nested/deep") {
+		t.Error("home page lists a hidden repository")
+	}
+
+	var repositories []*apiRepository
+	err = json.Unmarshal(get("/api/v1/repos").Body.Bytes(), &repositories)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var project *apiRepository
+	for _, repository := range repositories {
+		if repository.Name == "team/project" {
+			project = repository
+		}
+
+		if repository.Name == "team/nested/deep" {
+			t.Error("API lists a hidden repository")
+		}
+	}
+
+	if project == nil {
+		t.Fatal("API does not list team/project")
+	}
+
+	want := apiRepository{
+		Name:        "team/project",
+		Description: "A namespaced project",
+		Owner:       "John Doe",
+		Homepage:    "https://project.example.org",
+		Section:     "Projects",
+		URL:         "https://fudge.example.org/team/project/",
+		CloneURL:    "https://fudge.example.org/team/project",
+	}
+	if *project != want {
+		t.Errorf("wrong repository: got %+v want %+v", *project, want)
+	}
+
+	// Hidden repositories are only left out of listings
+	status := get("/api/v1/repos/team/nested/deep").Code
+	if status != http.StatusOK {
+		t.Errorf("wrong status code for a hidden repository: got %v want %v",
+			status, http.StatusOK)
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"", "master"},
+		{"release/0.1", "release/0.1"},
+		{"nonexistent", "master"},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{
+			RepoRoot: "../git/testdata/repository",
+			Repositories: map[string]config.RepositoryConfig{
+				"python": {DefaultBranch: test.branch},
+			},
+		}
+
+		h, err := NewHandler(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request, err := http.NewRequest("GET", "/api/v1/repos/python", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		var details struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+
+		err = json.Unmarshal(recorder.Body.Bytes(), &details)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if details.DefaultBranch != test.want {
+			t.Errorf("wrong default branch for %q: got %q want %q",
+				test.branch, details.DefaultBranch, test.want)
+		}
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	createRepository(t, filepath.Join(dir, "repo"), []string{"README.md"})
+
+	description := filepath.Join(dir, "repo", ".git", "description")
+	setDescription := func(contents string, modTime time.Time) {
+		err := ioutil.WriteFile(description, []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chtimes(description, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The description is modified after the repository was created
+	start := time.Now().Add(time.Hour)
+	setDescription("Before", start)
+
+	h, err := NewHandler(&config.Config{RepoRoot: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getDescription := func() string {
+		metadata, err := h.getMetadata(nil, "repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return metadata.Description
+	}
+
+	if got := getDescription(); got != "Before" {
+		t.Errorf("wrong description: got %q want %q", got, "Before")
+	}
+
+	// The cached metadata is kept while the files are not modified
+	setDescription("Unchanged", start)
+
+	if got := getDescription(); got != "Before" {
+		t.Errorf("wrong cached description: got %q want %q", got, "Before")
+	}
+
+	setDescription("After", start.Add(time.Minute))
+
+	if got := getDescription(); got != "After" {
+		t.Errorf("wrong modified description: got %q want %q", got, "After")
+	}
+}
+
+func TestUnreadableMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	createRepository(t, filepath.Join(dir, "good"), []string{"README.md"})
+	createRepository(t, filepath.Join(dir, "bad"), []string{"README.md"})
+
+	// The description of bad cannot be read
+	description := filepath.Join(dir, "bad", ".git", "description")
+	err = os.RemoveAll(description)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(description, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHandler(&config.Config{RepoRoot: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := new(bytes.Buffer)
+	h.Logger = log.New(logs, "", 0)
+
+	for _, url := range []string{"/", "/api/v1/repos", "/activity.atom"} {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				url, recorder.Code, http.StatusOK)
+		}
+
+		body := recorder.Body.String()
+		if !strings.Contains(body, "good") || strings.Contains(body, "bad") {
+			t.Errorf("expected %s to only list the readable repository, got %q", url, body)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "Could not read the metadata of bad") {
+		t.Errorf("expected the unreadable repository to be logged, got %q", logs)
+	}
+}
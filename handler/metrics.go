@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type handlerMetrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler // Serves the metrics in the Prometheus text format
+
+	requests           *prometheus.CounterVec
+	requestDurations   *prometheus.HistogramVec
+	repositoryOpens    prometheus.Counter
+	cacheRequests      *prometheus.CounterVec
+	highlightDurations *prometheus.HistogramVec
+	blobBytes          prometheus.Counter
+}
+
+func newHandlerMetrics() *handlerMetrics {
+	r := prometheus.NewRegistry()
+
+	m := &handlerMetrics{
+		registry: r,
+		handler:  promhttp.HandlerFor(r, promhttp.HandlerOpts{}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fudge_http_requests_total",
+			Help: "The number of HTTP requests served.",
+		}, []string{"route", "status"}),
+		requestDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fudge_http_request_duration_seconds",
+			Help:    "The time taken to serve HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		repositoryOpens: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "fudge_repository_opens_total",
+			Help: "The number of repositories opened.",
+		}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fudge_cache_requests_total",
+			Help: "The number of cache lookups.",
+		}, []string{"cache", "result"}),
+		highlightDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fudge_highlight_duration_seconds",
+			Help:    "The time taken to highlight blobs, lines and diffs.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"kind"}),
+		blobBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "fudge_blob_bytes_total",
+			Help: "The number of bytes of raw blobs served.",
+		}),
+	}
+
+	r.MustRegister(m.requests, m.requestDurations, m.repositoryOpens,
+		m.cacheRequests, m.highlightDurations, m.blobBytes)
+
+	return m
+}
+
+// lookup counts a lookup in the given cache.
+func (m *handlerMetrics) lookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	m.cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// highlighted records the time taken to highlight something of the given kind
+// since start.
+func (m *handlerMetrics) highlighted(kind string, start time.Time) {
+	m.highlightDurations.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
+type routeKey struct{}
+
+// recordRoute is a middleware recording the path template of the route
+// matched by requests, which labels their metrics.
+func (h *Handler) recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := r.Context().Value(routeKey{}).(*string)
+		if ok {
+			if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+				*route = template
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder records the status code of responses.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// instrument serves r using next, counting it and recording its duration.
+func (h *Handler) instrument(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	// Requests matching no route are labelled "none"
+	route := "none"
+	recorder := &statusRecorder{ResponseWriter: w}
+
+	ctx := context.WithValue(r.Context(), routeKey{}, &route)
+	next.ServeHTTP(recorder, r.WithContext(ctx))
+
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+
+	status := strconv.Itoa(recorder.status)
+
+	h.metrics.requests.WithLabelValues(route, status).Inc()
+	h.metrics.requestDurations.WithLabelValues(route, status).
+		Observe(time.Since(start).Seconds())
+}
+
+// serveMetrics serves the metrics on the router, unless they are disabled or
+// served on their own listener.
+func (h *Handler) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	cfg := h.config(r).Metrics
+	if !cfg.Enable || cfg.Listener != nil {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	h.metrics.handler.ServeHTTP(w, r)
+}
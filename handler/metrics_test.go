@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestMetrics(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Cache:    config.DefaultCacheConfig,
+		Metrics:  config.MetricsConfig{Enable: true},
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	for _, url := range []string{
+		"/python/blob/master/README.md",
+		"/python/blob/master/README.md",
+		"/python/raw/master/README.md",
+		"/python/commit/3c255e3f5a626bc816102e91e2d8f8f94f733ed5",
+		"/python/blame/master/README.md",
+		"/nonexistent/",
+		"/static/nonexistent",
+	} {
+		get(url)
+	}
+
+	body := get("/metrics").Body.String()
+	for _, want := range []string{
+		`fudge_http_requests_total{route="/{repository}/blob/{spec:.*}",status="200"} 2`,
+		`fudge_http_requests_total{route="none",status="404"} 1`,
+		`fudge_http_requests_total{route="/static/",status="404"} 1`,
+		`fudge_http_request_duration_seconds_count{route="/{repository}/raw/{spec:.*}",status="200"} 1`,
+		`fudge_repository_opens_total 1`,
+		`fudge_cache_requests_total{cache="blobs",result="hit"} 1`,
+		`fudge_cache_requests_total{cache="blobs",result="miss"} 1`,
+		`fudge_cache_requests_total{cache="repositories",result="hit"} 4`,
+		`fudge_cache_requests_total{cache="repositories",result="miss"} 1`,
+		`fudge_highlight_duration_seconds_count{kind="blob"} 1`,
+		`fudge_highlight_duration_seconds_count{kind="diff"} 1`,
+		`fudge_highlight_duration_seconds_count{kind="lines"} 1`,
+		`fudge_blob_bytes_total 60`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+
+	cfg.Metrics.Listener = &config.ListenerConfig{Address: "localhost:9100"}
+
+	if status := get("/metrics").Code; status != http.StatusNotFound {
+		t.Errorf("wrong status code for metrics served on their own listener: got %v want %v",
+			status, http.StatusNotFound)
+	}
+}
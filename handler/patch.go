@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"bovarys.me/fudge/git"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// sendPatch sends a commit formatted as an email patch.
+func (h *Handler) sendPatch(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	commit, err := git.ResolveRevision(repository, vars["hash"])
+	if err == plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	b := new(bytes.Buffer)
+
+	err = git.WritePatch(b, commit, 1, 1)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	b.WriteTo(w)
+}
+
+// deferredWriter sets the Content-Type header of a response on its first
+// write, so that errors occurring before can still be sent as error pages.
+type deferredWriter struct {
+	http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (w *deferredWriter) start() {
+	if !w.started {
+		w.started = true
+		w.Header().Set("Content-Type", w.contentType)
+	}
+}
+
+func (w *deferredWriter) Write(b []byte) (int, error) {
+	w.start()
+
+	return w.ResponseWriter.Write(b)
+}
+
+// sendMbox sends the commits made on the head revision since it diverged from
+// the base revision, formatted as a series of email patches.
+func (h *Handler) sendMbox(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	base, head, ok := splitCompareSpec(vars["spec"])
+	if !ok {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	cmp, err := h.getComparison(r, repository, base, head, maxComparedCommits)
+	if err == plumbing.ErrReferenceNotFound {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// A partial series would not apply, so it is not sent at all
+	if cmp.Truncated {
+		http.Error(w, fmt.Sprintf("Ranges of more than %d commits cannot be downloaded",
+			maxComparedCommits), http.StatusUnprocessableEntity)
+		return
+	}
+
+	mbox := &deferredWriter{ResponseWriter: w, contentType: "text/plain; charset=utf-8"}
+
+	// The patches are streamed, so errors can only be reported to the client
+	// until the first one has been sent
+	err = git.WriteMbox(mbox, cmp.Commits)
+	if err != nil && !mbox.started {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if err != nil {
+		h.logError(r, err)
+		return
+	}
+
+	mbox.start()
+}
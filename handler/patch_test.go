@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bovarys.me/fudge/config"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestPatches(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url      string
+		status   int
+		contains []string
+	}{
+		{"/python/commit/fcd547424101b07adbd1e6cf4a06305342ae8f66.patch", http.StatusOK, []string{
+			"Subject: [PATCH] Edit README.md\n",
+		}},
+		{"/python/commit/nonexistent.patch", http.StatusNotFound, nil},
+		{"/python/compare/v0.1.0...release/0.1.mbox", http.StatusOK, []string{
+			"Subject: [PATCH] Add tests\n",
+		}},
+		{"/python/compare/v0.1.0...rename.mbox", http.StatusOK, []string{
+			"Subject: [PATCH 1/3] Add tests\n",
+			"Subject: [PATCH 3/3] Rename hello.py to main.py\n",
+		}},
+		{"/python/compare/nonexistent...master.mbox", http.StatusNotFound, nil},
+		{"/python/compare/master.mbox", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, recorder.Code, test.status)
+		}
+
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		want := "text/plain; charset=utf-8"
+		if recorder.Header().Get("Content-Type") != want {
+			t.Errorf("wrong content type for %s: got %q want %q", test.url,
+				recorder.Header().Get("Content-Type"), want)
+		}
+
+		body := recorder.Body.String()
+		for _, s := range test.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("expected %s to contain %q", test.url, s)
+			}
+		}
+	}
+}
+
+func TestMboxErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "long")
+	createRepository(t, path, []string{"README.md"})
+
+	repository, err := gogit.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i <= maxComparedCommits; i++ {
+		_, err = worktree.Commit(fmt.Sprintf("Commit %d", i), &gogit.CommitOptions{
+			Author: &object.Signature{Name: "fudge", Email: "fudge@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		RepoRoot: dir,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := new(bytes.Buffer)
+	h.Logger = log.New(logs, "", 0)
+
+	get := func(w http.ResponseWriter, url string) {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h.Router.ServeHTTP(w, request)
+	}
+
+	recorder := httptest.NewRecorder()
+	get(recorder, fmt.Sprintf("/long/compare/master~%d...master.mbox", maxComparedCommits+1))
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wrong status code for a long range: got %v want %v",
+			recorder.Code, http.StatusUnprocessableEntity)
+	}
+
+	get(failingWriter{httptest.NewRecorder()}, "/long/compare/master~1...master.mbox")
+
+	want := "Could not answer GET /long/compare/master~1...master.mbox: connection reset"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("expected the error to be logged, got %q", logs)
+	}
+}
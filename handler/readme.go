@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"bovarys.me/fudge/git"
+	"bovarys.me/fudge/util"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type readme struct {
+	Name     string
+	Contents template.HTML // The rendered README, if it is a Markdown file
+	Text     string        // The README contents otherwise
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+
+	return ext == ".md" || ext == ".markdown"
+}
+
+// getReadme returns the README file found in the objects of the tree at dir,
+// or nil if there is none. Relative links and images of Markdown files are
+// rewritten to point to fudge's tree, blob and raw URLs.
+func getReadme(repository, rev, dir string, c *object.Commit, objects []*git.TreeObject) (*readme, error) {
+	name := git.GetReadmeName(objects)
+	if name == "" {
+		return nil, nil
+	}
+
+	blob, err := git.GetRepositoryBlob(c, path.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Reader.Close()
+
+	if blob.IsBinary {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadAll(blob.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMarkdown(name) {
+		return &readme{Name: name, Text: string(contents)}, nil
+	}
+
+	resolve := func(view string) func(string) string {
+		return func(dest string) string {
+			u, err := url.Parse(dest)
+			if err != nil {
+				return dest
+			}
+
+			p := path.Join(dir, u.Path)
+			if strings.HasPrefix(u.Path, "/") {
+				p = path.Clean(u.Path)[1:]
+			}
+			if p == "." {
+				p = ""
+			}
+
+			// Leave links pointing outside of the repository untouched
+			if strings.HasPrefix(p, "..") {
+				return dest
+			}
+
+			v := view
+			if v == "blob" {
+				_, err := git.GetRepositoryTree(c, p)
+				if err == nil {
+					v = "tree"
+				}
+			}
+
+			u.Path = fmt.Sprintf("/%s/%s/%s/%s", repository, v, rev, p)
+
+			return u.String()
+		}
+	}
+
+	html := util.Markdown(contents, resolve("blob"), resolve("raw"))
+
+	return &readme{Name: name, Contents: template.HTML(html)}, nil
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// repositoryRoute prefixes the path templates of the repository routes in
+// metrics. Repository names contain slashes when namespaced, so they are
+// resolved before routing the rest of the path: matching them with a pattern
+// would mistake a directory named "tree" or "log" for a route.
+const repositoryRoute = "/{repository}"
+
+// setRepositoryRoutes sets the routes of the pages of repositories, relative
+// to the repository path, e.g. "/tree/master".
+func (h *Handler) setRepositoryRoutes(router *mux.Router) {
+	router.HandleFunc("/", h.showTree)
+	router.HandleFunc("/commits", h.showCommits)
+	router.HandleFunc("/commits.atom", h.sendCommitsFeed)
+	router.HandleFunc("/tags", h.showTags)
+	router.HandleFunc("/tags.atom", h.sendTagsFeed)
+	router.HandleFunc("/commits/{spec:.*}", h.showCommits)
+	router.HandleFunc("/commit/{hash}.patch", h.sendPatch)
+	router.HandleFunc("/commit/{hash}", h.showCommit)
+	router.HandleFunc("/compare", h.redirectCompare)
+	router.HandleFunc("/compare/{spec:.*}.mbox", h.sendMbox)
+	router.HandleFunc("/compare/{spec:.*}", h.showCompare)
+	router.HandleFunc("/log/{spec:.*}", h.showCommits)
+	router.HandleFunc("/tree/{spec:.*}", h.showTree)
+	router.HandleFunc("/blob/{spec:.*}", h.showBlob)
+	router.HandleFunc("/blame/{spec:.*}", h.showBlame)
+	router.HandleFunc("/raw/{spec:.*}", h.sendBlob)
+	router.HandleFunc("/archive/{spec:.*}", h.sendArchive)
+	router.HandleFunc("/info/refs", h.advertiseRefs).Methods("GET")
+	router.HandleFunc("/git-upload-pack", h.uploadPack).Methods("POST")
+}
+
+// splitRepositoryPath splits path into the name of the repository it starts
+// with and the rest of the path, which is empty or starts with a slash. The
+// shortest name of a repository of the repo root is taken, a ".git" suffix
+// being ignored. ok is false if path does not start with the name of a
+// repository the user of r can read, so that private repositories are answered
+// for exactly as missing ones.
+func (h *Handler) splitRepositoryPath(r *http.Request, path string) (name, rest string, ok bool, err error) {
+	names, err := h.listRepositoryNames(r)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	repositories := toSet(names)
+
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' {
+			continue
+		}
+
+		name := strings.TrimSuffix(path[:i], ".git")
+		if repositories[name] && h.canRead(r, name) {
+			return path[:i], path[i:], true, nil
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// serveRepository serves the pages of repositories.
+func (h *Handler) serveRepository(w http.ResponseWriter, r *http.Request) {
+	h.dispatchRepository(w, r, "/", h.repositoryRouter)
+}
+
+// serveAPIRepository serves the API endpoints of repositories.
+func (h *Handler) serveAPIRepository(w http.ResponseWriter, r *http.Request) {
+	h.dispatchRepository(w, r, apiPrefix+"/repos/", h.apiRepositoryRouter)
+}
+
+// dispatchRepository resolves the name of the repository following prefix in
+// the request path, then serves the request with the route of router matching
+// the rest of the path. The repository variable of the request is set to the
+// name.
+func (h *Handler) dispatchRepository(w http.ResponseWriter, r *http.Request, prefix string, router *mux.Router) {
+	// Until a route is matched within the repository, the request matches none
+	route, _ := r.Context().Value(routeKey{}).(*string)
+	if route != nil {
+		*route = "none"
+	}
+
+	name, rest, ok, err := h.splitRepositoryPath(r, strings.TrimPrefix(r.URL.Path, prefix))
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		h.sendRepositoryNotFound(w, r)
+		return
+	}
+
+	if rest == "" {
+		if isAPIRequest(r) {
+			rest = "/"
+		} else {
+			u := *r.URL
+			u.Path += "/"
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	routed := r.Clone(r.Context())
+	routed.URL.Path, routed.URL.RawPath = rest, ""
+
+	var match mux.RouteMatch
+	if !router.Match(routed, &match) {
+		if match.MatchErr == mux.ErrMethodMismatch {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	if route != nil {
+		if template, err := match.Route.GetPathTemplate(); err == nil {
+			*route = strings.TrimSuffix(prefix, "/") + repositoryRoute + template
+		}
+	}
+
+	match.Vars["repository"] = name
+
+	match.Handler.ServeHTTP(w, mux.SetURLVars(r, match.Vars))
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bovarys.me/fudge/config"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// createRepository creates a repository at path with a commit of files on
+// the master branch.
+func createRepository(t *testing.T, path string, files []string) {
+	repository, err := gogit.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range files {
+		err = os.MkdirAll(filepath.Join(path, filepath.Dir(name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(path, name), []byte(name+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = worktree.Add(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = worktree.Commit("Add files", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "fudge", Email: "fudge@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouteKeywords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Directories and repositories are named like routes
+	files := []string{"tree/a.txt", "commits/b.txt", "docs/log/c.txt", "x/tags/d.txt"}
+	createRepository(t, filepath.Join(dir, "repo"), files)
+	createRepository(t, filepath.Join(dir, "tree", "log"), files)
+
+	cfg := &config.Config{
+		RepoRoot:  dir,
+		RepoDepth: 2,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/repo/blob/master/tree/a.txt", http.StatusOK},
+		{"/repo/tree/master/commits", http.StatusOK},
+		{"/repo/blob/master/docs/log/c.txt", http.StatusOK},
+		{"/repo/tree/master/x/tags", http.StatusOK},
+		{"/repo/blob/master/x/tags/d.txt", http.StatusOK},
+		{"/repo/log/master/tree/a.txt", http.StatusOK},
+		{"/repo/tags", http.StatusOK},
+		{"/repo/blob/master/tree/nonexistent", http.StatusNotFound},
+		{"/repo/nonexistent", http.StatusNotFound},
+		{"/repo", http.StatusMovedPermanently},
+		{"/tree/log/", http.StatusOK},
+		{"/tree/log/tree/master/tree", http.StatusOK},
+		{"/tree/log/blob/master/commits/b.txt", http.StatusOK},
+		{"/tree/log/commits", http.StatusOK},
+		{"/tree/", http.StatusNotFound},
+		{"/api/v1/repos/repo", http.StatusOK},
+		{"/api/v1/repos/repo/blob/master/x/tags/d.txt", http.StatusOK},
+		{"/api/v1/repos/repo/tree/master/commits", http.StatusOK},
+		{"/api/v1/repos/tree/log/tree/master/tree", http.StatusOK},
+		{"/api/v1/repos/tree", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		status := recorder.Code
+		if status != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, status, test.status)
+		}
+	}
+
+	request, err := http.NewRequest("POST", "/repo/info/refs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("wrong status code for POST /repo/info/refs: got %v want %v",
+			recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"bovarys.me/fudge/assets"
+	"bovarys.me/fudge/auth"
+	"bovarys.me/fudge/config"
+	"bovarys.me/fudge/logger"
+
+	"github.com/gorilla/handlers"
+)
+
+// state holds what depends on the config, and is replaced as a whole when the
+// config is reloaded.
+type state struct {
+	config *config.Config
+	caches *caches
+	auth   *auth.Auth
+	css    map[string][]byte // The syntax highlighting stylesheet of each theme
+	tmpl   map[string]*template.Template
+	static http.Handler // Serves the static files
+	router http.Handler // The router, wrapped by the request logger if enabled
+	writer io.Writer    // The request logger writer, nil if it is disabled
+
+	created time.Time
+
+	mu       sync.Mutex // Guards the fields below
+	requests int        // The number of requests being served
+	retired  bool       // Whether the state was replaced by a reload
+}
+
+type stateKey struct{}
+
+// state returns the state r is served with, captured when it was received so
+// that a reload does not change the config in the middle of a response. The
+// current state is returned if r is nil.
+func (h *Handler) state(r *http.Request) *state {
+	if r != nil {
+		if s, ok := r.Context().Value(stateKey{}).(*state); ok {
+			return s
+		}
+	}
+
+	return h.current.Load().(*state)
+}
+
+func (h *Handler) config(r *http.Request) *config.Config {
+	return h.state(r).config
+}
+
+func (h *Handler) caches(r *http.Request) *caches {
+	return h.state(r).caches
+}
+
+// acquire counts a request served with s. It returns false if s was retired
+// in the meantime, the request having to be served with the current state.
+func (s *state) acquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retired {
+		return false
+	}
+
+	s.requests++
+
+	return true
+}
+
+// release counts the end of a request served with s. It reports whether s
+// was retired and this was its last request, its writer having to be closed.
+func (s *state) release() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests--
+
+	return s.retired && s.requests == 0
+}
+
+// retire marks s as replaced by a reload. It reports whether no request is
+// being served with s, its writer having to be closed right away.
+func (s *state) retire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retired = true
+
+	return s.requests == 0
+}
+
+// newState returns the state of the handler for cfg. The caches of previous
+// are kept unless the options they depend on changed.
+func (h *Handler) newState(cfg *config.Config, previous *state) (*state, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &state{
+		config:  cfg,
+		router:  h.router,
+		created: time.Now(),
+	}
+
+	if previous != nil && previous.config.Cache == cfg.Cache &&
+		previous.config.RepoRoot == cfg.RepoRoot &&
+		previous.config.RepoDepth == cfg.RepoDepth {
+		s.caches = previous.caches
+	} else {
+		s.caches = newCaches(&cfg.Cache)
+	}
+
+	// The htpasswd file is read again on reloads
+	s.auth, err = auth.New(&cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates and static files are read again on reloads, so that they can
+	// be overridden without restarting
+	files := assets.New(cfg.AssetsDir)
+
+	s.tmpl, err = parseTemplates(files)
+	if err != nil {
+		return nil, err
+	}
+
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		return nil, err
+	}
+
+	s.static = http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+
+	s.css, err = newSyntaxCSS(&cfg.Syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	loggerConfig, ok := cfg.Loggers["router"]
+	if ok && loggerConfig.Enable {
+		writer, err := logger.Writer(loggerConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		s.writer = writer
+		s.router = handlers.CombinedLoggingHandler(writer, h.router)
+	}
+
+	return s, nil
+}
+
+// keepStartupOptions sets the options of cfg which are only read at startup
+// to their previous value, and returns the names of those which changed.
+func keepStartupOptions(cfg, previous *config.Config) []string {
+	var changed []string
+
+	if !reflect.DeepEqual(cfg.Listeners, previous.Listeners) {
+		changed = append(changed, "listeners")
+		cfg.Listeners = previous.Listeners
+	}
+
+	if cfg.Timeouts != previous.Timeouts {
+		changed = append(changed, "timeouts")
+		cfg.Timeouts = previous.Timeouts
+	}
+
+	if !reflect.DeepEqual(cfg.Metrics.Listener, previous.Metrics.Listener) {
+		changed = append(changed, "metrics.listener")
+		cfg.Metrics.Listener = previous.Metrics.Listener
+	}
+
+	return changed
+}
+
+// Reload replaces the config of the handler with cfg. Log files are opened
+// again, so that they can be rotated. If cfg is invalid, the current config is
+// kept and an error is returned. Changes to the listeners and timeouts are
+// ignored with a warning, as they require a restart.
+func (h *Handler) Reload(cfg *config.Config) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous := h.state(nil)
+
+	copied := *cfg
+	cfg = &copied
+
+	for _, option := range keepStartupOptions(cfg, previous.config) {
+		h.Logger.Printf("Changes to the %s option require a restart and were ignored", option)
+	}
+
+	s, err := h.newState(cfg, previous)
+	if err != nil {
+		return err
+	}
+
+	h.current.Store(s)
+
+	// The previous writer is closed once the requests being served with it
+	// are logged
+	if previous.retire() {
+		return closeWriter(previous)
+	}
+
+	return nil
+}
+
+// closeWriter closes the request logger writer of s, if any.
+func closeWriter(s *state) error {
+	if s.writer == nil {
+		return nil
+	}
+
+	err := logger.Close(s.writer)
+	s.writer = nil
+
+	return err
+}
+
+// Close closes the request logger writer. Requests served afterwards are not
+// logged.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return closeWriter(h.state(nil))
+}
+
+// acquireState returns the current state, counting a request served with it.
+func (h *Handler) acquireState() *state {
+	for {
+		s := h.state(nil)
+		if s.acquire() {
+			return s
+		}
+	}
+}
+
+func (h *Handler) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.acquireState()
+
+	defer func() {
+		if s.release() {
+			err := closeWriter(s)
+			if err != nil {
+				h.Logger.Println("Could not close request logger:", err)
+			}
+		}
+	}()
+
+	ctx := context.WithValue(r.Context(), stateKey{}, s)
+	h.instrument(s.router, w, r.WithContext(ctx))
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newConfig := func(description, log string) *config.Config {
+		return &config.Config{
+			RepoRoot: "../git/testdata/repository",
+			Descriptions: map[string]string{
+				"python": description,
+			},
+			Loggers: map[string]config.LoggerConfig{
+				"router": {
+					Enable: true,
+					Mode:   "file",
+					Path:   filepath.Join(dir, log),
+				},
+			},
+		}
+	}
+
+	h, err := NewHandler(newConfig("Before", "before.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() string {
+		request, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder.Body.String()
+	}
+
+	if !strings.Contains(get(), "Before") {
+		t.Error("body does not contain the initial description")
+	}
+
+	err = h.Reload(newConfig("After", "after.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(get(), "After") {
+		t.Error("body does not contain the reloaded description")
+	}
+
+	for _, log := range []string{"before.log", "after.log"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, log))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Count(string(b), "GET / ") != 1 {
+			t.Errorf("expected %s to contain a single request, got %q", log, b)
+		}
+	}
+
+	invalid := newConfig("Invalid", "invalid.log")
+	invalid.RepoRoot = "nonexistent"
+
+	err = h.Reload(invalid)
+	if err == nil {
+		t.Error("expected an invalid config to be rejected")
+	}
+
+	if !strings.Contains(get(), "After") {
+		t.Error("expected the previous config to be kept")
+	}
+
+	err = h.Close()
+	if err != nil {
+		t.Errorf("could not close the handler: %s", err)
+	}
+
+	err = h.Close()
+	if err != nil {
+		t.Errorf("could not close the handler twice: %s", err)
+	}
+}
+
+func TestAssetsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"static/css", "template"} {
+		err = os.MkdirAll(filepath.Join(dir, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write := func(name, contents string) {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("static/css/fudge.css", "body { color: red; }\n")
+	write("template/404.html", `{{define "content"}}Overridden{{end}}`)
+
+	cfg := &config.Config{
+		RepoRoot:  "../git/testdata/repository",
+		AssetsDir: dir,
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) (int, string) {
+		request, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder.Code, recorder.Body.String()
+	}
+
+	code, body := get("/static/css/fudge.css")
+	if code != http.StatusOK || body != "body { color: red; }\n" {
+		t.Errorf("expected the stylesheet to be overridden, got %d %q", code, body)
+	}
+
+	code, body = get("/static/img/tree.svg")
+	if code != http.StatusOK || !strings.Contains(body, "<svg") {
+		t.Errorf("expected the bundled image to be served, got %d %q", code, body)
+	}
+
+	_, body = get("/nonexistent/")
+	if !strings.Contains(body, "Overridden") {
+		t.Errorf("expected the 404 template to be overridden, got %q", body)
+	}
+
+	write("template/404.html", `{{define "content"}}Reloaded{{end}}`)
+
+	err = h.Reload(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, body = get("/nonexistent/")
+	if !strings.Contains(body, "Reloaded") {
+		t.Errorf("expected the 404 template to be read again, got %q", body)
+	}
+
+	write("template/404.html", `{{define "content"}}{{end`)
+
+	err = h.Reload(cfg)
+	if err == nil {
+		t.Error("expected an invalid template to be rejected")
+	}
+
+	_, body = get("/nonexistent/")
+	if !strings.Contains(body, "Reloaded") {
+		t.Error("expected the previous templates to be kept")
+	}
+}
+
+func TestReloadInFlight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{
+		RepoRoot:  "../git/testdata/repository",
+		Listeners: []config.ListenerConfig{{Address: "localhost:8080"}},
+		Timeouts:  config.DefaultTimeoutsConfig,
+		Loggers: map[string]config.LoggerConfig{
+			"router": {
+				Enable: true,
+				Mode:   "file",
+				Path:   filepath.Join(dir, "router.log"),
+			},
+		},
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := new(bytes.Buffer)
+	h.Logger = log.New(logs, "", 0)
+
+	// A request is being served while the config is reloaded
+	s := h.acquireState()
+
+	reloaded := *cfg
+	reloaded.Listeners = []config.ListenerConfig{{Address: "localhost:9090"}}
+	reloaded.Timeouts.Read = time.Minute
+
+	err = h.Reload(&reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.writer == nil {
+		t.Error("expected the previous writer to be kept open for the request")
+	}
+
+	if !s.release() {
+		t.Error("expected the previous writer to be closed after the request")
+	}
+
+	err = closeWriter(s)
+	if err != nil {
+		t.Errorf("could not close the previous writer: %s", err)
+	}
+
+	if s.acquire() {
+		t.Error("expected the previous state not to serve new requests")
+	}
+
+	if h.state(nil) == s {
+		t.Error("expected the state to be replaced")
+	}
+
+	current := h.config(nil)
+	if current.Listeners[0].Address != "localhost:8080" || current.Timeouts != cfg.Timeouts {
+		t.Errorf("expected the listeners and timeouts to be kept, got %+v %+v",
+			current.Listeners, current.Timeouts)
+	}
+
+	for _, option := range []string{"listeners", "timeouts"} {
+		want := "Changes to the " + option + " option require a restart"
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected a warning about %s, got %q", option, logs)
+		}
+	}
+
+	if strings.Contains(logs.String(), "metrics.listener") {
+		t.Errorf("unexpected warning about the metrics listener: %q", logs)
+	}
+
+	err = h.Close()
+	if err != nil {
+		t.Errorf("could not close the handler: %s", err)
+	}
+}
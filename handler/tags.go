@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"bovarys.me/fudge/git"
+)
+
+// showTags lists the tags of a repository, sorted by version unless the sort
+// query parameter is "date".
+func (h *Handler) showTags(w http.ResponseWriter, r *http.Request) {
+	repository, err := h.openRepository(w, r)
+	if err != nil {
+		return
+	}
+
+	tags, err := git.GetRepositoryTags(repository)
+	if err != nil {
+		h.showError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	latest := git.GetLatestRelease(tags)
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "date" {
+		sortBy = "version"
+		git.SortTagsByVersion(tags)
+	}
+
+	params := h.getParams(r)
+
+	params["Tags"] = tags
+	params["LatestRelease"] = latest
+	params["Sort"] = sortBy
+
+	h.state(r).tmpl["tags"].ExecuteTemplate(w, "layout", params)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestTags(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string) (int, string) {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		return recorder.Code, recorder.Body.String()
+	}
+
+	for _, url := range []string{"/python/tags", "/python/tags?sort=date"} {
+		status, body := get(url)
+		if status != http.StatusOK {
+			t.Fatalf("wrong status code for %s: got %v want %v", url, status,
+				http.StatusOK)
+		}
+
+		contains := []string{
+			"Now with tests.",
+			"Lightweight tag",
+			`<a href="/python/archive/v0.1.0.zip">zip</a>`,
+			`<a href="/python/commit/8018d114b13d3b65862d450cf77189344ac094c1">8018d11</a>`,
+		}
+		for _, s := range contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("expected %s to contain %q", url, s)
+			}
+		}
+
+		if strings.Count(body, `<span class="badge">Latest release</span>`) != 1 {
+			t.Errorf("expected %s to show a single latest release badge", url)
+		}
+
+		if strings.Index(body, "v0.2.0") > strings.Index(body, "v0.1.0") {
+			t.Errorf("expected %s to list v0.2.0 first", url)
+		}
+	}
+
+	_, body := get("/python/")
+	if !strings.Contains(body, "Latest release v0.2.0") {
+		t.Error("expected the repository page to show the latest release")
+	}
+
+	_, body = get("/python/tree/master/src")
+	if strings.Contains(body, "Latest release") {
+		t.Error("expected the latest release to only be shown on the repository page")
+	}
+
+	status, _ := get("/nonexistent/tags")
+	if status != http.StatusNotFound {
+		t.Errorf("wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
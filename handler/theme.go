@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"bovarys.me/fudge/config"
+	"bovarys.me/fudge/util"
+
+	"github.com/gorilla/mux"
+)
+
+// The name of the cookie holding the theme chosen by visitors
+const themeCookie = "theme"
+
+// The themes visitors can choose. Without a theme, the stylesheets follow the
+// system preference.
+var themes = map[string]bool{
+	"light": true,
+	"dark":  true,
+}
+
+// newSyntaxCSS returns the syntax highlighting stylesheet of each theme, using
+// the default styles of the themes whose style is not set.
+func newSyntaxCSS(cfg *config.SyntaxConfig) (map[string][]byte, error) {
+	css := make(map[string][]byte)
+
+	for theme, names := range map[string][2]string{
+		"light": {cfg.Light, config.DefaultSyntaxConfig.Light},
+		"dark":  {cfg.Dark, config.DefaultSyntaxConfig.Dark},
+	} {
+		name := names[0]
+		if name == "" {
+			name = names[1]
+		}
+
+		style, err := util.GetStyle(name, cfg.Styles)
+		if err != nil {
+			return nil, err
+		}
+
+		var b bytes.Buffer
+
+		err = util.WriteCSS(&b, style)
+		if err != nil {
+			return nil, err
+		}
+
+		css[theme] = b.Bytes()
+	}
+
+	return css, nil
+}
+
+// getTheme returns the theme chosen by the visitor, or an empty string if
+// there is none.
+func getTheme(r *http.Request) string {
+	cookie, err := r.Cookie(themeCookie)
+	if err != nil || !themes[cookie.Value] {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func (h *Handler) sendSyntaxCSS(w http.ResponseWriter, r *http.Request) {
+	s := h.state(r)
+
+	css, ok := s.css[mux.Vars(r)["theme"]]
+	if !ok {
+		h.showError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	http.ServeContent(w, r, "", s.created, bytes.NewReader(css))
+}
+
+// setTheme stores the theme chosen by the visitor in a cookie, or removes it
+// if the theme is empty, and redirects them to the page they came from.
+func (h *Handler) setTheme(w http.ResponseWriter, r *http.Request) {
+	cookie := &http.Cookie{
+		Name:     themeCookie,
+		Value:    r.PostFormValue("theme"),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if !themes[cookie.Value] {
+		cookie.Value = ""
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, cookie)
+
+	// Only redirect to pages of fudge
+	url := r.PostFormValue("return")
+	if !strings.HasPrefix(url, "/") || strings.HasPrefix(url, "//") ||
+		strings.HasPrefix(url, "/\\") {
+		url = "/"
+	}
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
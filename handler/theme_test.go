@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"bovarys.me/fudge/config"
+)
+
+func TestSyntaxCSS(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+		Syntax: config.SyntaxConfig{
+			Light: "custom",
+			Styles: map[string]map[string]string{
+				"custom": {"Keyword": "bold #123456"},
+			},
+		},
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		status int
+		want   string
+	}{
+		{"/syntax/light.css", http.StatusOK, ".chroma .k { color: #123456; font-weight: bold }"},
+		{"/syntax/dark.css", http.StatusOK, ".chroma .k { color: #ff8f40 }"},
+		{"/syntax/auto.css", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.status {
+			t.Errorf("wrong status code for %s: got %v want %v",
+				test.url, recorder.Code, test.status)
+		}
+
+		if !strings.Contains(recorder.Body.String(), test.want) {
+			t.Errorf("%s does not contain %s", test.url, test.want)
+		}
+	}
+
+	for _, styles := range []config.SyntaxConfig{
+		{Dark: "nonexistent"},
+		{Light: "custom", Styles: map[string]map[string]string{
+			"custom": {"Nonexistent": "#123456"},
+		}},
+	} {
+		cfg.Syntax = styles
+
+		_, err := NewHandler(cfg)
+		if err == nil {
+			t.Errorf("expected %+v to be rejected", styles)
+		}
+	}
+}
+
+func TestTheme(t *testing.T) {
+	cfg := &config.Config{
+		RepoRoot: "../git/testdata/repository",
+	}
+
+	h, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		theme    string
+		returnTo string
+		location string
+		cookie   string
+		links    []string
+	}{
+		{"dark", "/python/", "/python/", "theme=dark", []string{
+			`href="/syntax/dark.css"`,
+			`href="/static/css/dark.css"`,
+		}},
+		{"light", "//example.com", "/", "theme=light", []string{
+			`href="/syntax/light.css"`,
+		}},
+		{"", "/", "/", "theme=", []string{
+			`href="/syntax/light.css" media="not all and (prefers-color-scheme: dark)"`,
+			`href="/syntax/dark.css" media="(prefers-color-scheme: dark)"`,
+		}},
+	}
+
+	for _, test := range tests {
+		form := url.Values{"theme": {test.theme}, "return": {test.returnTo}}
+
+		request, err := http.NewRequest("POST", "/theme",
+			strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		recorder := httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusSeeOther {
+			t.Errorf("wrong status code for %q: got %v want %v",
+				test.theme, recorder.Code, http.StatusSeeOther)
+		}
+
+		if location := recorder.Header().Get("Location"); location != test.location {
+			t.Errorf("wrong redirection for %q: got %s want %s",
+				test.theme, location, test.location)
+		}
+
+		cookie := recorder.Header().Get("Set-Cookie")
+		if !strings.HasPrefix(cookie, test.cookie+";") {
+			t.Errorf("wrong cookie for %q: got %s", test.theme, cookie)
+		}
+
+		request, err = http.NewRequest("GET", "/python/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.theme != "" {
+			request.AddCookie(&http.Cookie{Name: themeCookie, Value: test.theme})
+		}
+
+		recorder = httptest.NewRecorder()
+		h.Router.ServeHTTP(recorder, request)
+
+		body := recorder.Body.String()
+		for _, link := range test.links {
+			if !strings.Contains(body, link) {
+				t.Errorf("page with theme %q does not contain %s", test.theme, link)
+			}
+		}
+	}
+}
@@ -58,3 +58,16 @@ func Writer(cfg config.LoggerConfig) (io.Writer, error) {
 		return nil, fmt.Errorf("unknown logger mode: %q", cfg.Mode)
 	}
 }
+
+// Close closes a writer returned by Writer, leaving the standard streams open.
+func Close(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+
+	if closer, ok := w.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"io"
+	"io/ioutil"
 	"log/syslog"
 	"os"
 	"testing"
@@ -45,3 +46,37 @@ func TestWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestClose(t *testing.T) {
+	file, err := ioutil.TempFile("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	writer, err := Writer(config.LoggerConfig{Mode: "file", Path: file.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	err = Close(writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = writer.Write([]byte("test"))
+	if err == nil {
+		t.Error("expected the file writer to be closed")
+	}
+
+	err = Close(os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stdout.Write([]byte{})
+	if err != nil {
+		t.Error("expected stdout to be left open")
+	}
+}
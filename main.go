@@ -1,16 +1,18 @@
 package main
 
-//go:generate go run generate.go
-
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"bovarys.me/fudge/config"
 	"bovarys.me/fudge/handler"
+	"bovarys.me/fudge/server"
 )
 
 var configPath string
@@ -20,6 +22,26 @@ func init() {
 	flag.Parse()
 }
 
+// reload reloads the config of h each time a SIGHUP signal is received,
+// keeping the current config if the new one is invalid.
+func reload(h *handler.Handler, logger *log.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		cfg, err := config.NewConfig(configPath)
+		if err == nil {
+			err = h.Reload(cfg)
+		}
+		if err != nil {
+			logger.Println("Could not reload config:", err)
+			continue
+		}
+
+		logger.Println("Reloaded config from", configPath)
+	}
+}
+
 func main() {
 	cfg, err := config.NewConfig(configPath)
 	if err != nil {
@@ -32,15 +54,82 @@ func main() {
 	}
 
 	logger := log.New(os.Stdout, "", log.LstdFlags)
+	h.Logger = logger
+
+	srv := server.New(cfg, h.Router, logger)
+
+	go reload(h, logger)
+
+	// Register for termination signals before serving, so that none of them
+	// kills the process while connections are open
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	servers := []*http.Server{srv}
+	errs := make(chan error, len(cfg.Listeners)+1)
+
+	for _, listenerConfig := range cfg.Listeners {
+		listener, err := server.Listen(listenerConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		logger.Println("Starting server on", listener)
+
+		go func(listener *server.Listener) {
+			errs <- listener.Serve(srv)
+		}(listener)
+	}
+
+	if cfg.Metrics.Enable && cfg.Metrics.Listener != nil {
+		listener, err := server.Listen(*cfg.Metrics.Listener)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	server := &http.Server{
-		Addr:         "localhost:8080",
-		Handler:      h.Router,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		ErrorLog:     logger,
+		metricsSrv := server.New(cfg, h.Metrics, logger)
+		servers = append(servers, metricsSrv)
+
+		logger.Println("Serving metrics on", listener)
+
+		go func() {
+			errs <- listener.Serve(metricsSrv)
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		log.Fatal(err)
+	case sig := <-signals:
+		logger.Printf("Received %s, shutting down", sig)
+	}
+
+	shutdown(servers, h, cfg.Timeouts.Shutdown, logger)
+}
+
+// shutdown stops the servers from accepting connections and waits for the
+// requests being served to complete, for at most timeout if it is not zero. It
+// then closes the request logger writers of h.
+func shutdown(servers []*http.Server, h *handler.Handler, timeout time.Duration, logger *log.Logger) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for _, srv := range servers {
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			logger.Println("Could not drain connections:", err)
+			srv.Close()
+		}
+	}
+
+	err := h.Close()
+	if err != nil {
+		logger.Println("Could not close request logger:", err)
 	}
 
-	logger.Println("Starting server on", server.Addr)
-	log.Fatal(server.ListenAndServe())
+	logger.Println("Server stopped")
 }
@@ -0,0 +1 @@
+package server // import "bovarys.me/fudge/server"
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"bovarys.me/fudge/config"
+)
+
+// Listener is a listener opened from a listener config.
+type Listener struct {
+	net.Listener
+
+	config config.ListenerConfig
+}
+
+// Listen opens a listener on the TCP address or the Unix domain socket of cfg.
+// Stale sockets left by a previous run are removed first.
+func Listen(cfg config.ListenerConfig) (*Listener, error) {
+	if cfg.Address != "" {
+		listener, err := net.Listen("tcp", cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Listener{listener, cfg}, nil
+	}
+
+	mode, err := cfg.Mode()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Lstat(cfg.Socket)
+	if err == nil && file.Mode()&os.ModeSocket != 0 {
+		err = os.Remove(cfg.Socket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		err = os.Chmod(cfg.Socket, mode)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	return &Listener{listener, cfg}, nil
+}
+
+// Serve serves HTTP requests, or HTTPS requests if the listener config has a
+// certificate, using s.
+func (l *Listener) Serve(s *http.Server) error {
+	if l.config.IsTLS() {
+		return s.ServeTLS(l.Listener, l.config.TLSCert, l.config.TLSKey)
+	}
+
+	return s.Serve(l.Listener)
+}
+
+// String returns the URL the listener can be reached at.
+func (l *Listener) String() string {
+	scheme := "http"
+	if l.config.IsTLS() {
+		scheme = "https"
+	}
+
+	if l.config.Socket != "" {
+		return fmt.Sprintf("%s+unix://%s", scheme, l.config.Socket)
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, l.Addr())
+}
+
+// New returns a server using the timeouts of cfg.
+func New(cfg *config.Config, handler http.Handler, logger *log.Logger) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+		ErrorLog:     logger,
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bovarys.me/fudge/config"
+)
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its key
+// to dir.
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"fudge"}},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certPath, keyPath
+}
+
+func TestListeners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fudge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeCertificate(t, dir)
+	socketPath := filepath.Join(dir, "fudge.sock")
+
+	// A stale socket left by a previous run
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	cfg := &config.Config{
+		Timeouts: config.DefaultTimeoutsConfig,
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fudge"))
+	})
+
+	srv := New(cfg, handler, log.New(ioutil.Discard, "", 0))
+	defer srv.Close()
+
+	tests := []struct {
+		config config.ListenerConfig
+		client *http.Client
+		url    string
+	}{
+		{
+			config.ListenerConfig{Address: "127.0.0.1:0"},
+			http.DefaultClient,
+			"http://%s/",
+		},
+		{
+			config.ListenerConfig{Address: "127.0.0.1:0", TLSCert: certPath, TLSKey: keyPath},
+			&http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}},
+			"https://%s/",
+		},
+		{
+			config.ListenerConfig{Socket: socketPath, SocketMode: "0600"},
+			&http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return net.Dial("unix", socketPath)
+				},
+			}},
+			"http://unix/",
+		},
+	}
+
+	for _, test := range tests {
+		listener, err := Listen(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go listener.Serve(srv)
+
+		url := test.url
+		if test.config.Address != "" {
+			url = fmt.Sprintf(url, listener.Addr())
+		}
+
+		response, err := test.client.Get(url)
+		if err != nil {
+			t.Fatalf("could not get %s: %v", listener, err)
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(body) != "fudge" {
+			t.Errorf("wrong body from %s: got %q", listener, body)
+		}
+	}
+
+	file, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Mode().Perm() != 0600 {
+		t.Errorf("wrong socket permissions: got %v want %v",
+			file.Mode().Perm(), os.FileMode(0600))
+	}
+}
@@ -10,15 +10,15 @@ type Breadcrumb struct {
 	Link string
 }
 
-func Breadcrumbs(name, path string) []*Breadcrumb {
+func Breadcrumbs(name, rev, path string) []*Breadcrumb {
+	current := fmt.Sprintf("/%s/tree/%s", name, rev)
+
 	crumbs := []*Breadcrumb{
 		{
 			Text: name,
-			Link: fmt.Sprintf("/%s", name),
+			Link: current,
 		},
 	}
-
-	current := fmt.Sprintf("/%s/tree", name)
 	parts := strings.Split(path, "/")
 
 	for _, part := range parts {
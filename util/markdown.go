@@ -0,0 +1,66 @@
+package util
+
+import (
+	"io"
+	"net/url"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// markdownRenderer is a HTML renderer rewriting the destination of relative
+// links and images.
+type markdownRenderer struct {
+	*blackfriday.HTMLRenderer
+
+	link  func(dest string) string
+	image func(dest string) string
+}
+
+func (r *markdownRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	if entering && isRelative(string(node.LinkData.Destination)) {
+		switch node.Type {
+		case blackfriday.Link:
+			dest := r.link(string(node.LinkData.Destination))
+			node.LinkData.Destination = []byte(dest)
+		case blackfriday.Image:
+			dest := r.image(string(node.LinkData.Destination))
+			node.LinkData.Destination = []byte(dest)
+		}
+	}
+
+	return r.HTMLRenderer.RenderNode(w, node, entering)
+}
+
+// isRelative reports whether dest is a link to another file of the
+// repository, as opposed to an absolute URL or a fragment.
+func isRelative(dest string) bool {
+	if dest == "" {
+		return false
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "" && u.Host == "" && u.Path != ""
+}
+
+// Markdown renders a Markdown document to HTML. Raw HTML is left out and only
+// links using safe protocols are kept, so that the output can be embedded in
+// a page as is. The destinations of relative links and images are rewritten
+// using the link and image functions.
+func Markdown(contents []byte, link, image func(dest string) string) string {
+	renderer := &markdownRenderer{
+		HTMLRenderer: blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+			Flags: blackfriday.SkipHTML | blackfriday.Safelink |
+				blackfriday.NofollowLinks | blackfriday.NoreferrerLinks,
+		}),
+		link:  link,
+		image: image,
+	}
+
+	output := blackfriday.Run(contents, blackfriday.WithRenderer(renderer))
+
+	return string(output)
+}
@@ -2,12 +2,16 @@ package util
 
 import (
 	"bytes"
+	"fmt"
+	"html/template"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	"github.com/alecthomas/chroma"
 	"github.com/alecthomas/chroma/formatters/html"
 	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
 )
 
 func getFormatter() *html.Formatter {
@@ -15,45 +19,64 @@ func getFormatter() *html.Formatter {
 		html.WithLineNumbers(), html.LineNumbersInTable())
 }
 
-func getStyle() (*chroma.Style, error) {
-	style, err := chroma.NewStyle("ayu-dark", chroma.StyleEntries{
-		chroma.Background:         " bg:#0a0e14",
-		chroma.Text:               "#b3b1ad",
-		chroma.Comment:            "#626a73",
-		chroma.Error:              "#ff3333",
-		chroma.GenericDeleted:     "#d96c75",
-		chroma.GenericInserted:    "#91b362",
-		chroma.Keyword:            "#ff8f40",
-		chroma.KeywordConstant:    "#ffee99",
-		chroma.KeywordType:        "#39bae6",
-		chroma.LiteralNumber:      "#ffee99",
-		chroma.LiteralString:      "#c2d94c",
-		chroma.LiteralStringChar:  "#95e6cb",
-		chroma.LiteralStringOther: "#95e6cb",
-		chroma.LiteralStringRegex: "#95e6cb",
-		chroma.NameAttribute:      "#ffb454",
-		chroma.NameClass:          "#ffb454",
-		chroma.NameDecorator:      "#e6b673",
-		chroma.NameNamespace:      "#ffb454",
-		chroma.NameTag:            "#39bae6",
-		chroma.OperatorWord:       "#f29668",
-	})
+// The default dark style
+var ayuDark = styles.Register(chroma.MustNewStyle("ayu-dark", chroma.StyleEntries{
+	chroma.Background:         " bg:#0a0e14",
+	chroma.Text:               "#b3b1ad",
+	chroma.Comment:            "#626a73",
+	chroma.Error:              "#ff3333",
+	chroma.GenericDeleted:     "#d96c75",
+	chroma.GenericInserted:    "#91b362",
+	chroma.Keyword:            "#ff8f40",
+	chroma.KeywordConstant:    "#ffee99",
+	chroma.KeywordType:        "#39bae6",
+	chroma.LiteralNumber:      "#ffee99",
+	chroma.LiteralString:      "#c2d94c",
+	chroma.LiteralStringChar:  "#95e6cb",
+	chroma.LiteralStringOther: "#95e6cb",
+	chroma.LiteralStringRegex: "#95e6cb",
+	chroma.NameAttribute:      "#ffb454",
+	chroma.NameClass:          "#ffb454",
+	chroma.NameDecorator:      "#e6b673",
+	chroma.NameNamespace:      "#ffb454",
+	chroma.NameTag:            "#39bae6",
+	chroma.OperatorWord:       "#f29668",
+}))
+
+// GetStyle returns the style with the given name, looked up in the custom
+// styles first and then in the chroma ones. The entries of custom styles map
+// token type names, e.g. "Keyword", to chroma style entries, e.g. "bold #f00".
+func GetStyle(name string, custom map[string]map[string]string) (*chroma.Style, error) {
+	entries, ok := custom[name]
+	if !ok {
+		style, ok := styles.Registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown syntax style: %q", name)
+		}
+
+		return style, nil
+	}
 
-	if err != nil {
-		return nil, err
+	types := make(map[string]chroma.TokenType)
+	for t := range chroma.StandardTypes {
+		types[t.String()] = t
 	}
 
-	return style, nil
-}
+	styleEntries := make(chroma.StyleEntries)
+	for typeName, entry := range entries {
+		t, ok := types[typeName]
+		if !ok {
+			return nil, fmt.Errorf("unknown token type in syntax style %q: %q",
+				name, typeName)
+		}
 
-func Highlight(filename string, r io.ReadCloser) (string, error) {
-	b, err := ioutil.ReadAll(r)
-	if err != nil {
-		return "", err
+		styleEntries[t] = entry
 	}
 
-	contents := string(b)
+	return chroma.NewStyle(name, styleEntries)
+}
 
+func getLexer(filename, contents string) chroma.Lexer {
 	lexer := lexers.Match(filename)
 	if lexer == nil {
 		lexer = lexers.Analyse(contents)
@@ -62,11 +85,23 @@ func Highlight(filename string, r io.ReadCloser) (string, error) {
 		lexer = lexers.Fallback
 	}
 
-	style, err := getStyle()
-	if err != nil {
-		return "", err
+	return lexer
+}
+
+// getClass returns the CSS class used by the HTML formatter for a token type.
+func getClass(t chroma.TokenType) string {
+	for ; t != 0; t = t.Parent() {
+		if class, ok := chroma.StandardTypes[t]; ok {
+			return class
+		}
 	}
 
+	return chroma.StandardTypes[t]
+}
+
+// highlight highlights contents using CSS classes, which are styled by the
+// stylesheets written by WriteCSS whatever the style used here.
+func highlight(lexer chroma.Lexer, contents string) (string, error) {
 	iterator, err := lexer.Tokenise(nil, contents)
 	if err != nil {
 		return "", err
@@ -75,7 +110,7 @@ func Highlight(filename string, r io.ReadCloser) (string, error) {
 	buffer := new(bytes.Buffer)
 	formatter := getFormatter()
 
-	err = formatter.Format(buffer, style, iterator)
+	err = formatter.Format(buffer, styles.Fallback, iterator)
 	if err != nil {
 		return "", err
 	}
@@ -83,14 +118,58 @@ func Highlight(filename string, r io.ReadCloser) (string, error) {
 	return buffer.String(), nil
 }
 
-func WriteCSS(w io.Writer) error {
-	style, err := getStyle()
+func Highlight(filename string, r io.ReadCloser) (string, error) {
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	formatter := getFormatter()
-	err = formatter.WriteCSS(w, style)
+	contents := string(b)
+
+	return highlight(getLexer(filename, contents), contents)
+}
+
+// HighlightLines highlights contents and returns one HTML fragment per line,
+// so that each line can be annotated separately.
+func HighlightLines(filename, contents string) ([]string, error) {
+	lexer := getLexer(filename, contents)
+
+	iterator, err := lexer.Tokenise(nil, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+
+	for _, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		var builder strings.Builder
+
+		for _, token := range tokens {
+			value := strings.TrimSuffix(token.Value, "\n")
+			value = strings.Replace(value, "\t", "    ", -1)
+			value = template.HTMLEscapeString(value)
+
+			class := getClass(token.Type)
+			if class == "" {
+				builder.WriteString(value)
+				continue
+			}
+
+			fmt.Fprintf(&builder, `<span class="%s">%s</span>`, class, value)
+		}
+
+		lines = append(lines, builder.String())
+	}
+
+	return lines, nil
+}
+
+// HighlightDiff highlights a unified diff.
+func HighlightDiff(patch string) (string, error) {
+	return highlight(lexers.Get("diff"), patch)
+}
 
-	return err
+// WriteCSS writes the stylesheet of the highlighted contents for style.
+func WriteCSS(w io.Writer, style *chroma.Style) error {
+	return getFormatter().WriteCSS(w, style)
 }